@@ -5,7 +5,10 @@ go 1.16
 require (
 	github.com/grijul/go-andotp v1.0.23
 	github.com/grijul/otpgen v1.0.0
-	github.com/mattn/go-runewidth v0.0.13 // indirect
+	github.com/makiuchi-d/gozxing v0.0.2
+	github.com/mattn/go-runewidth v0.0.13
 	github.com/rodaine/table v1.0.1
+	golang.org/x/crypto v0.0.0-20210506145944-38f3c27a63bf
+	golang.org/x/sys v0.0.0-20210601080250-7ecdf8ef093b
 	golang.org/x/term v0.0.0-20210503060354-a79de5458b56
 )
@@ -1,53 +1,64 @@
 package args
 
 import (
-	"encoding/base32"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/grijul/zauth/internal/clipboard"
 	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/oauthurl"
 	"github.com/grijul/zauth/internal/otp"
+	"github.com/grijul/zauth/internal/qr"
+	"github.com/grijul/zauth/internal/watch"
 	"github.com/grijul/zauth/internal/zauth"
 	"github.com/grijul/zauth/third_party"
 	"github.com/mattn/go-runewidth"
 	"github.com/rodaine/table"
+	"golang.org/x/term"
 )
 
 type ZAuthArgsEntry struct{}
 
 type SecretReader interface {
-	ReadSecret(common.ZAuthCommonComp) (string, error)
+	ReadSecret(common.ZAuthCommonComp, string) (string, error)
 }
 
 type IssuerReader interface {
-	ReadIssuer(common.ZAuthCommonComp) (string, error)
+	ReadIssuer(common.ZAuthCommonComp, string) (string, error)
 }
 
 type IdentifierReader interface {
-	ReadIdentifier(common.ZAuthCommonComp) (string, error)
+	ReadIdentifier(common.ZAuthCommonComp, string) (string, error)
 }
 
 type TypeReader interface {
-	ReadType(common.ZAuthCommonComp) (string, error)
+	ReadType(common.ZAuthCommonComp, string) (string, error)
 }
 
 type DigitsReader interface {
-	ReadDigits(common.ZAuthCommonComp) (int, error)
+	ReadDigits(common.ZAuthCommonComp, int) (int, error)
 }
 
 type AlgorithmReader interface {
-	ReadAlgorithm(common.ZAuthCommonComp) (string, error)
+	ReadAlgorithm(common.ZAuthCommonComp, string) (string, error)
 }
 
 type CounterReader interface {
-	ReadCounter(common.ZAuthCommonComp) (int64, error)
+	ReadCounter(common.ZAuthCommonComp, int64) (int64, error)
 }
 
 type PeriodReader interface {
-	ReadPeriod(common.ZAuthCommonComp) (int64, error)
+	ReadPeriod(common.ZAuthCommonComp, int64) (int64, error)
 }
 
 type ZAuthArgsEntryInput interface {
@@ -61,15 +72,48 @@ type ZAuthArgsEntryInput interface {
 	IssuerReader
 }
 
-const usage = `COMMANDS:
-  entry			zauth entry operations (add/edit/delete/list) (see zauth entry --help)
+const usage = `Prints codes of all entries, or of entries matching query (issuer, identifier or tag, fuzzy match).
+A query equal to a command name runs the command instead (see zauth entry -list).
+
+OPTIONS:
+  -dir string
+	zauth data directory (default: $ZAUTH_DIR, else $HOME/.zauth or $XDG_DATA_HOME/zauth on linux)
+  -password-file string
+	read vault password from file, instead of prompting for it (default: $ZAUTH_PASSWORD if set)
+  -output string
+	output format of codes and entry listings: table, json, csv or tsv (default: table)
+  -secrets
+	include secrets in json, csv and tsv output
+  -tag string
+	only list entries having tag (codes, entry listings and watch)
+  -sort string
+	sort order of codes and entry listings: issuer, label, recent or frequency (default: vault order)
+  -vault string
+	vault to operate on (default: default)
+
+COMMANDS:
+  entry			zauth entry operations (add/edit/delete/restore/purge/list) (see zauth entry --help)
   import		import file(s) to zauth (see zauth import --help)
-  export		export zauth entries to file (see zauth export --help)`
+  export		export zauth entries to file (see zauth export --help)
+  code			print OTP code of an entry (see zauth code --help)
+  vault			vault operations (list/create/remove/rename) (see zauth vault --help)
+  watch			live-updating full-screen view of all codes (see zauth watch --help)`
 
 func ParseArgs(zc common.ZAuthCommonComp) error {
 	var msg string
 	ze := &ZAuthArgsEntry{}
 
+	// global options
+	// A new FlagSet is used on every call, so that ParseArgs can be called several times (eg: in tests)
+	globalCmd := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	globalDir := globalCmd.String("dir", "", "zauth data directory")
+	globalVault := globalCmd.String("vault", common.DefaultVault, "vault to operate on")
+	globalOutput := globalCmd.String("output", OutputTable, "output format")
+	globalSecrets := globalCmd.Bool("secrets", false, "include secrets in output")
+	globalSort := globalCmd.String("sort", common.SortNone, "sort order")
+	globalTag := globalCmd.String("tag", "", "only list entries having tag")
+	globalPasswordFile := globalCmd.String("password-file", "", "read vault password from file")
+
 	// import cmd
 	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
 	importType := importCmd.String("type", "", "Import type (required)")
@@ -85,20 +129,92 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 	// entry cmd
 	entryCmd := flag.NewFlagSet("entry", flag.ExitOnError)
 	entryNew := entryCmd.Bool("new", false, "Create new entry")
-	entryEdit := entryCmd.Bool("edit", false, "Edit existing entry")
-	entryDelete := entryCmd.Bool("delete", false, "Delete existing entry")
-	entryList := entryCmd.Bool("list", false, "List all entries")
+	entryUri := entryCmd.String("uri", "", "Create new entries from otpauth:// URI instead of prompting for each field. Use - to read URIs from stdin (one per line) (optional)")
+	entryEdit := entryCmd.Bool("edit", false, "Edit existing entry selected by index, ID, issuer or label (eg: zauth entry -edit 2)")
+	entryDelete := entryCmd.Bool("delete", false, "Delete existing entries selected by index, ID, issuer, label or glob pattern (eg: zauth entry -delete 'Git*')")
+	entryRestore := entryCmd.Bool("restore", false, "Restore deleted entries selected by index, ID, issuer, label or glob pattern. Deleted entries are listed if none is selected")
+	entryPurge := entryCmd.Bool("purge", false, "Permanently remove deleted entries selected by index, ID, issuer, label or glob pattern from trash (eg: zauth entry -purge '*' empties trash)")
+	entryForce := entryCmd.Bool("force", false, "Delete or purge entries without confirmation (optional)")
+	entryList := entryCmd.Bool("list", false, "List all entries, or entries matching query (issuer, identifier or tag, fuzzy match) (eg: zauth entry -list git)")
+	entryShow := entryCmd.Bool("show", false, "Show details (notes, URL, metadata, tags, usage..) of entry selected by index, ID, issuer or label. Secret is only shown with -output and -secrets")
+	entryShowUri := entryCmd.Bool("show-uri", false, "Print otpauth:// URI of entry selected by index, ID, issuer or label")
+	entryQr := entryCmd.Bool("qr", false, "Print QR code of entry selected by index, ID, issuer or label (scan to add entry to another app)")
+	entryAddTag := entryCmd.String("add-tag", "", "Add comma separated tags to entries selected by index, ID, issuer, label or glob pattern (eg: zauth entry -add-tag work 'Git*')")
+	entryRemoveTag := entryCmd.String("remove-tag", "", "Remove comma separated tags from entries selected by index, ID, issuer, label or glob pattern")
+	entryFields := newEntryFlags(entryCmd)
+
+	// code cmd
+	codeCmd := flag.NewFlagSet("code", flag.ExitOnError)
+	codeNext := codeCmd.Bool("next", false, "Increment counter and generate next code of HOTP entry (eg: zauth code GitHub --next)")
+	codeRemaining := codeCmd.Bool("remaining", false, "Print remaining seconds of TOTP code after the code (eg: 123456 17)")
+	codeCopy := codeCmd.Bool("copy", false, "Copy code to clipboard instead of printing it. Clipboard is cleared after -clear timeout or when TOTP period rolls over")
+	codeClear := codeCmd.Duration("clear", 20*time.Second, "Clear clipboard after timeout when -copy is used. 0 keeps code in clipboard")
+
+	// vault cmd
+	vaultCmd := flag.NewFlagSet("vault", flag.ExitOnError)
+	vaultForce := vaultCmd.Bool("force", false, "Remove vault without confirmation (optional)")
+
+	// watch cmd
+	watchCmd := flag.NewFlagSet("watch", flag.ExitOnError)
+	watchClear := watchCmd.Duration("clear", 20*time.Second, "Clear clipboard after timeout when a code is copied. 0 keeps code in clipboard")
+
+	globalCmd.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] [COMMAND|query]\n\n%v\n", os.Args[0], usage)
+	}
+
+	globalCmd.Parse(os.Args[1:])
+	args := globalCmd.Args()
+
+	err := validateOutput(*globalOutput, *globalSecrets)
+	if err == nil {
+		err = common.ValidateSort(*globalSort)
+	}
+	if err != nil {
+		msg = err.Error()
+		fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+		return fmt.Errorf(msg)
+	}
+
+	dir, err := common.DataDir(*globalDir)
+	if err != nil {
+		msg = fmt.Sprintf("An error occured while reading zauth directory: %v", err)
+		fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+		return fmt.Errorf(msg)
+	}
+
+	pr, err := vaultPasswordReader(zc, *globalPasswordFile)
+	if err != nil {
+		msg = fmt.Sprintf("An error occured while reading vault password: %v", err)
+		fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+		return fmt.Errorf(msg)
+	}
+
+	st, err := common.NewVaultStore(dir, *globalVault, pr)
+	if err != nil {
+		msg = err.Error()
+		fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+		return fmt.Errorf(msg)
+	}
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [COMMAND]\n\n%v\n", os.Args[0], usage)
+	// named vaults must be created explicitly, so that a mistyped name does not create a new vault
+	if *globalVault != common.DefaultVault && (len(args) == 0 || args[0] != "vault") {
+		ok, err := common.VaultExists(dir, *globalVault)
+		if err == nil && !ok {
+			err = common.ErrVaultNotExist
+		}
+		if err != nil {
+			msg = fmt.Sprintf("%s: %v (see zauth vault create)", *globalVault, err)
+			fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+			return fmt.Errorf(msg)
+		}
 	}
 
-	flag.Parse()
+	lo := listOptions{tag: *globalTag, sort: *globalSort, output: *globalOutput, secrets: *globalSecrets}
 
-	if len(os.Args) == 1 {
-		return printZAuthOtpTable()
+	if len(args) == 0 {
+		return printZAuthOtpTable(st, lo)
 	} else {
-		switch os.Args[1] {
+		switch args[0] {
 		case "import":
 			{
 				var pwd string
@@ -107,7 +223,7 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 					fmt.Fprintf(flag.CommandLine.Output(), "\nSupported import types: %v\n\n", strings.Join(third_party.SupportedImportTypes, ", "))
 				}
 
-				importCmd.Parse(os.Args[2:])
+				importCmd.Parse(args[1:])
 
 				if *importType == "" {
 					msg = "import type cannot be empty"
@@ -124,7 +240,7 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 				}
 
 				if *importFileDecrypt {
-					fmt.Print("Password: ")
+					fmt.Fprint(os.Stderr, "Password: ")
 					p, err := zc.ReadPassword()
 					if err != nil {
 						msg = fmt.Sprintf("An error occured while capturing password: %v", err)
@@ -140,7 +256,7 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 					pwd = p
 				}
 
-				tpi, err := third_party.NewImportFile(importType)
+				tpi, err := third_party.NewImportFile(importType, st)
 				if err != nil {
 					msg = fmt.Sprintf("An error occured while importing file: %v", err)
 					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
@@ -161,10 +277,10 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 				var pwd string
 				exportCmd.Usage = func() {
 					printUsage("export", exportCmd)
-					fmt.Fprintf(flag.CommandLine.Output(), "\nSupported export types: %v\n\n", strings.Join(third_party.SupportedImportTypes, ", "))
+					fmt.Fprintf(flag.CommandLine.Output(), "\nSupported export types: %v\n\n", strings.Join(third_party.SupportedExportTypes, ", "))
 				}
 
-				exportCmd.Parse(os.Args[2:])
+				exportCmd.Parse(args[1:])
 
 				if *exportType == "" {
 					msg = "export type cannot be empty"
@@ -174,7 +290,7 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 				}
 
 				if *exportFileEncrypt {
-					fmt.Print("Password: ")
+					fmt.Fprint(os.Stderr, "Password: ")
 					p, err := zc.ReadPassword()
 					if err != nil {
 						msg = fmt.Sprintf("An error occured while capturing password: %v", err)
@@ -189,7 +305,7 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 					pwd = p
 				}
 
-				tpe, err := third_party.NewExportFile(exportType)
+				tpe, err := third_party.NewExportFile(exportType, st)
 				if err != nil {
 					msg = fmt.Sprintf("An error occured while exporting file: %v", err)
 					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
@@ -213,224 +329,845 @@ func ParseArgs(zc common.ZAuthCommonComp) error {
 					printUsage("entry", entryCmd)
 				}
 
-				entryCmd.Parse(os.Args[2:])
+				// flags may follow entry query (eg: zauth entry -edit GitHub -notes "...")
+				q := strings.Join(parseInterspersed(entryCmd, args[1:]), " ")
+
+				// entry field flags are rejected by operations which do not use them
+				var bad []string
+				switch {
+				case *entryNew && *entryUri != "":
+					if bad = entryFields.unsupported(entryCmd, append([]string{"tags"}, entryDetailFlags...)...); len(bad) > 0 {
+						msg = fmt.Sprintf("%s cannot be used with -uri (fields are read from URI)", strings.Join(bad, ", "))
+					}
+				case *entryNew:
+				case *entryEdit:
+					if bad = entryFields.unsupported(entryCmd, entryDetailFlags...); len(bad) > 0 {
+						msg = fmt.Sprintf("%s can only be used with -new (-edit prompts for entry fields)", strings.Join(bad, ", "))
+					}
+				default:
+					if bad = entryFields.unsupported(entryCmd); len(bad) > 0 {
+						msg = fmt.Sprintf("%s can only be used with -new or -edit", strings.Join(bad, ", "))
+					}
+				}
+				if len(bad) > 0 {
+					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+					return fmt.Errorf(msg)
+				}
+
+				if *entryNew && *entryUri != "" {
+					uris := []string{*entryUri}
+					if *entryUri == "-" {
+						var err error
+						uris, err = readLines(zc)
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while reading URIs: %v", err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+					}
+
+					zl := make([]zauth.ZAuth, 0, len(uris))
+					for i, u := range uris {
+						z, err := oauthurl.Parse(u)
+						if err == nil {
+							err = validateZAuth(z)
+						}
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while parsing URI %d: %v", i+1, err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+						z.Tags = common.ParseTags(*entryFields.tags)
+						_, err = entryFields.applyDetails(z, entryCmd)
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while parsing URI %d: %v", i+1, err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+						zl = append(zl, *z)
+					}
+
+					if len(zl) == 0 {
+						msg = "no URIs found"
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					err := st.WriteZAuthJson(zl, false)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while creating entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Printf("\n%d entries created successfully!\n", len(zl))
+					return nil
+
+				} else if *entryNew {
+					var in ZAuthArgsEntryInput = ze
+					if fi := newFlagEntryInput(ze, entryFields, entryCmd); fi != nil {
+						if bad := fi.inapplicable(); len(bad) > 0 {
+							msg = fmt.Sprintf("%s cannot be used with %s entries", strings.Join(bad, ", "), defaultString(strings.ToLower(*entryFields.typ), zauth.DefaultType))
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+
+						// missing fields cannot be prompted for when stdin is not a terminal (eg: in scripts)
+						if m := fi.missing(); len(m) > 0 && !stdinIsTerminal() {
+							msg = fmt.Sprintf("missing required fields: %s", strings.Join(m, ", "))
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+						in = fi
+					}
 
-				if *entryNew {
 					z := &zauth.ZAuth{}
 					fmt.Println("zauth new entry")
 					fmt.Printf("-----------------------\n\n")
 
-					sec, err := ze.ReadSecret(zc)
+					err := readEntry(in, zc, z)
+					if err != nil {
+						msg = err.Error()
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+					z.Tags = common.ParseTags(*entryFields.tags)
+					_, err = entryFields.applyDetails(z, entryCmd)
+					if err != nil {
+						msg = err.Error()
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					err = st.WriteZAuthJson([]zauth.ZAuth{*z}, false)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while creating entry: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Println("\n1 entry created successfully!")
+					return nil
+
+				} else if *entryList {
+					lst, err := st.ReadZAuthJson()
 					if err != nil {
-						msg = fmt.Sprintf("An error occured while reading secret: %v", err)
+						msg = fmt.Sprintf("An error occured while listing entries: %v", err)
 						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 						return fmt.Errorf(msg)
 					}
-					z.Secret = sec
 
-					iss, err := ze.ReadIssuer(zc)
+					lo.query = q
+					idx, err := listEntries(lst, lo)
 					if err != nil {
-						msg = fmt.Sprintf("An error occured while reading issuer: %v", err)
+						msg = err.Error()
 						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 						return fmt.Errorf(msg)
 					}
-					z.Issuer = iss
 
-					id, err := ze.ReadIdentifier(zc)
+					if lo.output != OutputTable {
+						err = writeEntries(os.Stdout, lo.output, lst, idx, lo.secrets)
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while listing entries: %v", err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+						return nil
+					}
+
+					fmt.Println("zauth entries")
+					fmt.Printf("-----------------------\n\n")
+					printEntries(lst, idx)
+					return nil
+				} else if *entryEdit {
+					// store is locked once entry is edited (see updateVault)
+					lst, err := st.ReadZAuthJson()
 					if err != nil {
-						msg = fmt.Sprintf("An error occured while reading identifier: %v", err)
+						msg = fmt.Sprintf("An error occured while reading entries: %v", err)
 						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 						return fmt.Errorf(msg)
 					}
-					z.Label = fmt.Sprintf("%s:%s", z.Issuer, id)
 
-					tp, err := ze.ReadType(zc)
+					i, err := selectEntry(zc, lst, q)
 					if err != nil {
-						msg = fmt.Sprintf("n error occured while reading type: %v", err)
+						msg = fmt.Sprintf("An error occured while selecting entry: %v", err)
 						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 						return fmt.Errorf(msg)
 					}
-					z.Type = tp
 
-					dt, err := ze.ReadDigits(zc)
+					z := lst[i]
+
+					// notes, URL and metadata supplied as flags are set without prompting other fields
+					set, err := entryFields.applyDetails(&z, entryCmd)
 					if err != nil {
-						msg = fmt.Sprintf("An error occured while reading digits: %v", err)
+						msg = err.Error()
 						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 						return fmt.Errorf(msg)
 					}
-					z.Digits = dt
 
-					if z.Type == "totp" {
+					if !set {
+						fmt.Printf("\nzauth edit entry [%d] (%s) %s\n", i+1, z.Issuer, z.Label)
+						fmt.Printf("-----------------------\n\n")
 
-						algo, err := ze.ReadAlgorithm(zc)
+						err = readEntry(ze, zc, &z)
 						if err != nil {
-							msg = fmt.Sprintf("An error occured while reading algorithm: %v", err)
+							msg = err.Error()
 							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 							return fmt.Errorf(msg)
 						}
-						z.Algorithm = algo
 
-						pd, err := ze.ReadPeriod(zc)
+						err = validateZAuth(&z)
 						if err != nil {
-							msg = fmt.Sprintf("An error occured while reading period: %v", err)
+							msg = fmt.Sprintf("invalid entry: %v", err)
 							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 							return fmt.Errorf(msg)
 						}
-						z.Period = pd
+					}
+
+					err = updateVault(st, func(v *zauth.ZAuthVault) error {
+						j := entryIndex(v.Entries, z.ID)
+						if j < 0 {
+							return errEntryChanged(&z)
+						}
+						v.Entries[j] = z
+						return nil
+					})
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while updating entry: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Println("\n1 entry updated successfully!")
+					return nil
+				} else if *entryDelete {
+					// store is locked once deletion is confirmed (see updateVault)
+					v, err := st.Read()
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while reading entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					idx, err := selectEntries(zc, v.Entries, q, true)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while selecting entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-					} else {
-						z.Algorithm = "sha1"
+					if !*entryForce {
+						fmt.Println("\nFollowing entries will be deleted:")
+						printEntries(v.Entries, idx)
 
-						ctr, err := ze.ReadCounter(zc)
+						ok, err := confirm(zc, fmt.Sprintf("\nDelete %d entries? (y/N): ", len(idx)))
 						if err != nil {
-							msg = fmt.Sprintf("An error occured while reading counter: %v", err)
+							msg = fmt.Sprintf("An error occured while reading confirmation: %v", err)
 							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 							return fmt.Errorf(msg)
 						}
-						z.Counter = ctr
+						if !ok {
+							fmt.Println("deletion cancelled")
+							return nil
+						}
 					}
 
-					err = common.WriteZAuthJson([]zauth.ZAuth{*z}, false)
+					sel := v.Entries
+					err = updateVault(st, func(v *zauth.ZAuthVault) error {
+						del := make(map[int]bool)
+						for _, i := range idx {
+							j := entryIndex(v.Entries, sel[i].ID)
+							if j < 0 {
+								return errEntryChanged(&sel[i])
+							}
+							del[j] = true
+						}
+
+						ent := make([]zauth.ZAuth, 0, len(v.Entries)-len(del))
+						for i, z := range v.Entries {
+							if del[i] {
+								v.Trash = append(v.Trash, zauth.ZAuthDeleted{ZAuth: z, Deleted: time.Now().Unix()})
+							} else {
+								ent = append(ent, z)
+							}
+						}
+						v.Entries = ent
+						return nil
+					})
 					if err != nil {
-						msg = fmt.Sprintf("An error occured while creating entry: %v", err)
+						msg = fmt.Sprintf("An error occured while deleting entries: %v", err)
 						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 						return fmt.Errorf(msg)
 					}
 
-					fmt.Println("\n1 entry created successfully!")
+					fmt.Printf("\n%d entries deleted successfully! (see zauth entry -restore)\n", len(idx))
 					return nil
+				} else if *entryAddTag != "" || *entryRemoveTag != "" {
+					// store is locked once entries are selected (see updateVault)
+					v, err := st.Read()
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while reading entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-				} else if *entryList {
-					lst, err := common.ReadZAuthJson()
+					idx, err := selectEntries(zc, v.Entries, q, true)
 					if err != nil {
-						msg = fmt.Sprintf("An error occured while listing entries: %v", err)
+						msg = fmt.Sprintf("An error occured while selecting entries: %v", err)
 						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 						return fmt.Errorf(msg)
 					}
 
-					fmt.Println("zauth entries")
-					fmt.Printf("-----------------------\n\n")
-					for i, l := range lst {
-						out := fmt.Sprintf("[%d] (%s) %s (%s)", i+1, l.Issuer, l.Label, strings.ToUpper(l.Type))
-						fmt.Println(out)
+					n := 0
+					sel := v.Entries
+					err = updateVault(st, func(v *zauth.ZAuthVault) error {
+						n = 0
+						for _, i := range idx {
+							j := entryIndex(v.Entries, sel[i].ID)
+							if j < 0 {
+								return errEntryChanged(&sel[i])
+							}
+
+							add := common.AddTags(&v.Entries[j], common.ParseTags(*entryAddTag))
+							rm := common.RemoveTags(&v.Entries[j], common.ParseTags(*entryRemoveTag))
+							if add || rm {
+								n++
+							}
+						}
+						return nil
+					})
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while updating tags: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
 					}
+
+					fmt.Printf("\n%d entries updated successfully!\n", n)
 					return nil
-				} else if *entryEdit {
-					msg = "functionality is yet to be implemented"
-					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
-					return fmt.Errorf(msg)
-				} else if *entryDelete {
-					msg = "functionality is yet to be implemented"
-					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
-					return fmt.Errorf(msg)
-				}
+				} else if *entryShow {
+					lst, err := st.ReadZAuthJson()
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while reading entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-				return nil
-			}
+					i, err := selectEntry(zc, lst, q)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while selecting entry: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-		default:
-			{
-				msg = "invalid input.\nPlease see -h for available commands"
-				fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
-				return fmt.Errorf(msg)
-			}
+					if lo.output != OutputTable {
+						cols, row := entryRecord(&lst[i], lo.secrets)
+						err = writeRecord(os.Stdout, lo.output, cols, row)
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while printing entry: %v", err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+						return nil
+					}
 
-		}
-	}
-}
+					fmt.Printf("\nzauth entry [%d]\n", i+1)
+					fmt.Printf("-----------------------\n\n")
+					printEntry(&lst[i])
+					return nil
+				} else if *entryShowUri || *entryQr {
+					lst, err := st.ReadZAuthJson()
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while reading entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-func (za *ZAuthArgsEntry) ReadSecret(zc common.ZAuthCommonComp) (string, error) {
-	for {
-		fmt.Print("Secret (required): ")
-		sec, err := zc.UserInput()
-		if err != nil {
-			return "", err
-		}
+					i, err := selectEntry(zc, lst, q)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while selecting entry: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-		sec = strings.ReplaceAll(strings.TrimSpace(sec), " ", "")
-		if sec == "" {
-			fmt.Fprint(flag.CommandLine.Output(), "secret cannot be empty\n")
-		} else {
-			_, err := base32.StdEncoding.DecodeString(sec)
-			if err != nil {
-				fmt.Fprint(flag.CommandLine.Output(), "invalid base32 secret. please try again.\n")
-			} else {
-				return sec, nil
-			}
-		}
-	}
-}
+					u := oauthurl.Format(&lst[i])
+					if *entryQr {
+						q, err := qr.Render(u)
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while generating QR code: %v", err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
 
-func (za *ZAuthArgsEntry) ReadIssuer(zc common.ZAuthCommonComp) (string, error) {
-	for {
-		fmt.Print("Issuer (eg: GitHub/Google..) (required): ")
-		iss, err := zc.UserInput()
-		if err != nil {
-			return "", err
-		}
+						fmt.Printf("\n(%s) %s\n\n", lst[i].Issuer, lst[i].Label)
+						fmt.Print(q)
+						fmt.Println()
+					}
 
-		iss = strings.TrimSpace(iss)
-		if iss == "" {
-			fmt.Fprint(flag.CommandLine.Output(), "issuer cannot be empty\n")
-		} else {
-			return iss, nil
-		}
-	}
-}
+					if *entryShowUri {
+						fmt.Println(u)
+					}
 
-func (za *ZAuthArgsEntry) ReadIdentifier(zc common.ZAuthCommonComp) (string, error) {
-	for {
-		fmt.Print("Identifier (eg: Username/email) (required): ")
-		acc, err := zc.UserInput()
-		if err != nil {
-			return "", err
-		}
-		acc = strings.TrimSpace(acc)
-		if acc == "" {
-			fmt.Fprint(flag.CommandLine.Output(), "identifier cannot be empty\n")
-		} else {
-			return acc, nil
-		}
-	}
-}
+					return nil
+				} else if *entryRestore {
+					// store is locked once entries are selected (see updateVault)
+					v, err := st.Read()
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while reading entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-func (za *ZAuthArgsEntry) ReadType(zc common.ZAuthCommonComp) (string, error) {
-	for {
-		fmt.Print("Type (TOTP/HOTP) (default: TOTP): ")
-		typ, err := zc.UserInput()
-		if err != nil {
-			return "", err
-		}
+					if len(v.Trash) == 0 {
+						msg = "no deleted entries found"
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-		typ = strings.TrimSpace(typ)
-		if typ == "" {
-			return zauth.DefaultType, nil
-		}
+					tz := deletedEntries(v.Trash)
+					idx, err := selectEntries(zc, tz, q, true)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while selecting entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
 
-		t := strings.ToLower(typ)
-		if t == "totp" || t == "hotp" {
-			return t, nil
-		} else {
-			fmt.Fprint(flag.CommandLine.Output(), "bad input\n")
-		}
-	}
-}
+					err = updateVault(st, func(v *zauth.ZAuthVault) error {
+						res := make(map[int]bool)
+						dz := deletedEntries(v.Trash)
+						for _, i := range idx {
+							j := entryIndex(dz, tz[i].ID)
+							if j < 0 {
+								return errEntryChanged(&tz[i])
+							}
+							res[j] = true
+						}
 
-func (za *ZAuthArgsEntry) ReadDigits(zc common.ZAuthCommonComp) (int, error) {
-	for {
-		fmt.Print("Digits (default: 6): ")
-		sdig, err := zc.UserInput()
-		if err != nil {
-			return 0, err
+						trash := make([]zauth.ZAuthDeleted, 0, len(v.Trash)-len(res))
+						for i, d := range v.Trash {
+							if res[i] {
+								v.Entries = append(v.Entries, d.ZAuth)
+							} else {
+								trash = append(trash, d)
+							}
+						}
+						v.Trash = trash
+						return nil
+					})
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while restoring entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Printf("\n%d entries restored successfully!\n", len(idx))
+					return nil
+				} else if *entryPurge {
+					// store is locked once removal is confirmed (see updateVault)
+					v, err := st.Read()
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while reading entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					if len(v.Trash) == 0 {
+						msg = "no deleted entries found"
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					tz := deletedEntries(v.Trash)
+					idx, err := selectEntries(zc, tz, q, true)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while selecting entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					if !*entryForce {
+						fmt.Println("\nFollowing deleted entries will be permanently removed:")
+						printEntries(tz, idx)
+
+						ok, err := confirm(zc, fmt.Sprintf("\nPermanently remove %d entries? They cannot be restored (y/N): ", len(idx)))
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while reading confirmation: %v", err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+						if !ok {
+							fmt.Println("purge cancelled")
+							return nil
+						}
+					}
+
+					// backup is removed, as it would still hold secrets of purged entries
+					err = writeVault(st, st.WritePurge, func(v *zauth.ZAuthVault) error {
+						pur := make(map[int]bool)
+						dz := deletedEntries(v.Trash)
+						for _, i := range idx {
+							j := entryIndex(dz, tz[i].ID)
+							if j < 0 {
+								return errEntryChanged(&tz[i])
+							}
+							pur[j] = true
+						}
+
+						trash := make([]zauth.ZAuthDeleted, 0, len(v.Trash)-len(pur))
+						for i, d := range v.Trash {
+							if !pur[i] {
+								trash = append(trash, d)
+							}
+						}
+						v.Trash = trash
+						return nil
+					})
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while purging entries: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Printf("\n%d entries purged successfully!\n", len(idx))
+					return nil
+				}
+
+				return nil
+			}
+
+		case "code":
+			{
+				codeCmd.Usage = func() {
+					fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s code [OPTIONS] <index|id|issuer|label>\n\nPrints OTP of the single entry matching query (exact or fuzzy match).\nExits with non-zero status if no entry or several entries match.\n\nOPTIONS:\n", os.Args[0])
+					codeCmd.PrintDefaults()
+				}
+
+				q := parseInterspersed(codeCmd, args[1:])
+
+				// vault is written to record usage (and counter of HOTP entries).
+				// It is read (and password prompted) before store is locked, then read again once locked
+				v, err := st.Read()
+				if err == nil {
+					err = st.Lock()
+				}
+				if err == nil {
+					defer st.Unlock()
+					v, err = st.Read()
+				}
+				if err != nil {
+					msg = fmt.Sprintf("An error occured while reading entries: %v", err)
+					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+					return fmt.Errorf(msg)
+				}
+
+				// code is used in scripts: entry is never prompted and stdout only contains the code
+				i, err := findEntry(v.Entries, strings.Join(q, " "))
+				if err != nil {
+					msg = fmt.Sprintf("An error occured while selecting entry: %v", err)
+					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+					return fmt.Errorf(msg)
+				}
+
+				z := &v.Entries[i]
+				var o *zauth.ZAuthOtp
+				if strings.ToLower(z.Type) != "hotp" {
+					o, err = otp.GenerateOTP(z)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while generating OTP: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+				} else {
+					if !*codeNext {
+						msg = "HOTP codes are generated on request only. Use --next to increment counter and generate next code"
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					o, err = otp.NextHOTP(z)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while generating OTP: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+				}
+
+				// counter is saved before code is shown, so that a code is never reused.
+				// Usage of TOTP entries is only informative (see zauth -sort): code is shown even if it cannot be saved,
+				// and it does not replace the backup of the last change
+				common.RecordUsage(z, time.Now())
+				if strings.ToLower(z.Type) == "hotp" {
+					err = st.Write(v)
+				} else {
+					err = st.WriteUsage(v)
+				}
+				if err != nil {
+					if strings.ToLower(z.Type) == "hotp" {
+						msg = fmt.Sprintf("An error occured while saving counter: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+					fmt.Fprintf(flag.CommandLine.Output(), "An error occured while saving usage: %v\n", err)
+				}
+
+				if *codeCopy {
+					// lock is not held while waiting to clear clipboard
+					st.Unlock()
+
+					err = copyOtp(newClipboard(), z, o, *codeClear)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while copying OTP: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+					return nil
+				}
+
+				if *globalOutput != OutputTable {
+					cols, row := otpRecord(z, o, nil, *globalSecrets)
+					err = writeRecord(os.Stdout, *globalOutput, cols, row)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while printing OTP: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+					return nil
+				}
+
+				if *codeRemaining && o.Remaining > 0 {
+					fmt.Printf("%s %d\n", o.Otp, o.Remaining)
+				} else {
+					fmt.Println(o.Otp)
+				}
+				return nil
+			}
+
+		case "vault":
+			{
+				vaultCmd.Usage = func() {
+					fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s vault [OPTIONS] list|create <name>|remove <name>|rename <name> <new name>\n\nOPTIONS:\n", os.Args[0])
+					vaultCmd.PrintDefaults()
+				}
+
+				va := parseInterspersed(vaultCmd, args[1:])
+				if len(va) == 0 {
+					va = []string{"list"}
+				}
+
+				switch {
+				case va[0] == "list" && len(va) == 1:
+					names, err := common.ListVaults(dir)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while listing vaults: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					for _, n := range names {
+						if n == *globalVault {
+							fmt.Printf("* %s\n", n)
+						} else {
+							fmt.Printf("  %s\n", n)
+						}
+					}
+					return nil
+
+				case va[0] == "create" && len(va) == 2:
+					err := common.CreateVault(dir, va[1], pr)
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while creating vault: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Printf("\nvault %s created successfully! (use zauth --vault %s)\n", va[1], va[1])
+					return nil
+
+				case va[0] == "remove" && len(va) == 2:
+					ok, err := common.VaultExists(dir, va[1])
+					if err == nil && !ok {
+						err = common.ErrVaultNotExist
+					}
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while removing vault: %s: %v", va[1], err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					if !*vaultForce {
+						ok, err := confirm(zc, fmt.Sprintf("Remove vault %s and all it's entries? This cannot be undone. (y/N): ", va[1]))
+						if err != nil {
+							msg = fmt.Sprintf("An error occured while reading confirmation: %v", err)
+							fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+							return fmt.Errorf(msg)
+						}
+						if !ok {
+							fmt.Println("removal cancelled")
+							return nil
+						}
+					}
+
+					err = common.RemoveVault(dir, va[1])
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while removing vault: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Printf("\nvault %s removed successfully!\n", va[1])
+					return nil
+
+				case va[0] == "rename" && len(va) == 3:
+					err := common.RenameVault(dir, va[1], va[2])
+					if err != nil {
+						msg = fmt.Sprintf("An error occured while renaming vault: %v", err)
+						fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+						return fmt.Errorf(msg)
+					}
+
+					fmt.Printf("\nvault %s renamed to %s successfully!\n", va[1], va[2])
+					return nil
+
+				default:
+					msg = "invalid vault command.\nPlease see zauth vault -h for usage"
+					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+					return fmt.Errorf(msg)
+				}
+			}
+
+		case "watch":
+			{
+				watchCmd.Usage = func() {
+					printUsage("watch", watchCmd)
+					fmt.Fprint(flag.CommandLine.Output(), "\nKEYS:\n  type to search, Up/Down (or Ctrl+P/Ctrl+N) to select\n  Enter to copy code of selected entry (generates next code of HOTP entries)\n  Esc to clear search or quit, Ctrl+C to quit\n")
+				}
+
+				watchCmd.Parse(args[1:])
+
+				err := watch.Run(st, *globalTag, newClipboard(), *watchClear)
+				if err != nil {
+					msg = fmt.Sprintf("An error occured while watching entries: %v", err)
+					fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+					return fmt.Errorf(msg)
+				}
+				return nil
+			}
+
+		default:
+			{
+				// codes of entries matching query. Commands take priority over queries
+				lo.query = strings.Join(args, " ")
+				return printZAuthOtpTable(st, lo)
+			}
+
+		}
+	}
+}
+
+func (za *ZAuthArgsEntry) ReadSecret(zc common.ZAuthCommonComp, def string) (string, error) {
+	for {
+		if def == "" {
+			fmt.Print("Secret (required): ")
+		} else {
+			fmt.Print("Secret (default: keep current): ")
+		}
+		sec, err := zc.UserInput()
+		if err != nil {
+			return "", err
+		}
+
+		sec = strings.ReplaceAll(strings.TrimSpace(sec), " ", "")
+		if sec == "" && def != "" {
+			return def, nil
+		}
+
+		err = validateSecret(sec)
+		if err != nil {
+			fmt.Fprintf(flag.CommandLine.Output(), "%v. please try again.\n", err)
+		} else {
+			return sec, nil
+		}
+	}
+}
+
+func (za *ZAuthArgsEntry) ReadIssuer(zc common.ZAuthCommonComp, def string) (string, error) {
+	for {
+		fmt.Printf("Issuer (eg: GitHub/Google..) (%s): ", requiredOrDefault(def))
+		iss, err := zc.UserInput()
+		if err != nil {
+			return "", err
+		}
+
+		iss = strings.TrimSpace(iss)
+		if iss == "" && def != "" {
+			return def, nil
+		}
+
+		if iss == "" {
+			fmt.Fprint(flag.CommandLine.Output(), "issuer cannot be empty\n")
+		} else {
+			return iss, nil
+		}
+	}
+}
+
+func (za *ZAuthArgsEntry) ReadIdentifier(zc common.ZAuthCommonComp, def string) (string, error) {
+	for {
+		fmt.Printf("Identifier (eg: Username/email) (%s): ", requiredOrDefault(def))
+		acc, err := zc.UserInput()
+		if err != nil {
+			return "", err
+		}
+		acc = strings.TrimSpace(acc)
+		if acc == "" && def != "" {
+			return def, nil
+		}
+
+		if acc == "" {
+			fmt.Fprint(flag.CommandLine.Output(), "identifier cannot be empty\n")
+		} else {
+			return acc, nil
+		}
+	}
+}
+
+func (za *ZAuthArgsEntry) ReadType(zc common.ZAuthCommonComp, def string) (string, error) {
+	for {
+		fmt.Printf("Type (TOTP/HOTP/STEAM) (default: %s): ", strings.ToUpper(def))
+		typ, err := zc.UserInput()
+		if err != nil {
+			return "", err
+		}
+
+		typ = strings.TrimSpace(typ)
+		if typ == "" {
+			return strings.ToLower(def), nil
+		}
+
+		t := strings.ToLower(typ)
+		if validateType(t) == nil {
+			return t, nil
+		} else {
+			fmt.Fprint(flag.CommandLine.Output(), "bad input\n")
+		}
+	}
+}
+
+func (za *ZAuthArgsEntry) ReadDigits(zc common.ZAuthCommonComp, def int) (int, error) {
+	for {
+		fmt.Printf("Digits (default: %d): ", def)
+		sdig, err := zc.UserInput()
+		if err != nil {
+			return 0, err
 		}
 
 		sdig = strings.TrimSpace(sdig)
 		if sdig == "" {
-			return zauth.DefaultDigits, nil
+			return def, nil
 		}
 
 		dig, err := strconv.Atoi(sdig)
-		if err != nil {
+		if err != nil || validateDigits(dig) != nil {
 			fmt.Fprint(flag.CommandLine.Output(), "bad input\n")
 		} else {
 			return dig, nil
@@ -438,9 +1175,9 @@ func (za *ZAuthArgsEntry) ReadDigits(zc common.ZAuthCommonComp) (int, error) {
 	}
 }
 
-func (za *ZAuthArgsEntry) ReadAlgorithm(zc common.ZAuthCommonComp) (string, error) {
+func (za *ZAuthArgsEntry) ReadAlgorithm(zc common.ZAuthCommonComp, def string) (string, error) {
 	for {
-		fmt.Print("Algorithm (sha1/sha256/sha512) (default: sha1): ")
+		fmt.Printf("Algorithm (sha1/sha256/sha512) (default: %s): ", strings.ToLower(def))
 		algo, err := zc.UserInput()
 		if err != nil {
 			return "", err
@@ -448,11 +1185,11 @@ func (za *ZAuthArgsEntry) ReadAlgorithm(zc common.ZAuthCommonComp) (string, erro
 
 		algo = strings.TrimSpace(algo)
 		if algo == "" {
-			return zauth.DefaultAlgo, nil
+			return strings.ToLower(def), nil
 		}
 
 		algo = strings.ToLower(algo)
-		if algo == "sha1" || algo == "sha256" || algo == "sha512" {
+		if validateAlgorithm(algo) == nil {
 			return algo, nil
 		} else {
 			fmt.Fprint(flag.CommandLine.Output(), "bad input\n")
@@ -460,10 +1197,10 @@ func (za *ZAuthArgsEntry) ReadAlgorithm(zc common.ZAuthCommonComp) (string, erro
 	}
 }
 
-func (za *ZAuthArgsEntry) ReadPeriod(zc common.ZAuthCommonComp) (int64, error) {
+func (za *ZAuthArgsEntry) ReadPeriod(zc common.ZAuthCommonComp, def int64) (int64, error) {
 
 	for {
-		fmt.Print("Period (default: 30): ")
+		fmt.Printf("Period (default: %d): ", def)
 		sper, err := zc.UserInput()
 		if err != nil {
 			return 0, err
@@ -471,10 +1208,10 @@ func (za *ZAuthArgsEntry) ReadPeriod(zc common.ZAuthCommonComp) (int64, error) {
 
 		sper = strings.TrimSpace(sper)
 		if sper == "" {
-			return zauth.DefaultPeriod, nil
+			return def, nil
 		}
 		per, err := strconv.ParseInt(sper, 10, 64)
-		if err != nil {
+		if err != nil || validatePeriod(per) != nil {
 			fmt.Fprint(flag.CommandLine.Output(), "bad input\n")
 		} else {
 			return per, nil
@@ -482,9 +1219,9 @@ func (za *ZAuthArgsEntry) ReadPeriod(zc common.ZAuthCommonComp) (int64, error) {
 	}
 }
 
-func (za *ZAuthArgsEntry) ReadCounter(zc common.ZAuthCommonComp) (int64, error) {
+func (za *ZAuthArgsEntry) ReadCounter(zc common.ZAuthCommonComp, def int64) (int64, error) {
 	for {
-		fmt.Print("Counter (default: 0): ")
+		fmt.Printf("Counter (default: %d): ", def)
 		sctr, err := zc.UserInput()
 		if err != nil {
 			return 0, err
@@ -492,11 +1229,11 @@ func (za *ZAuthArgsEntry) ReadCounter(zc common.ZAuthCommonComp) (int64, error)
 
 		sctr = strings.TrimSpace(sctr)
 		if sctr == "" {
-			return zauth.DefaultCounter, nil
+			return def, nil
 		}
 
 		ctr, err := strconv.ParseInt(sctr, 10, 64)
-		if err != nil {
+		if err != nil || validateCounter(ctr) != nil {
 			fmt.Fprint(flag.CommandLine.Output(), "bad input\n")
 		} else {
 			return ctr, nil
@@ -504,41 +1241,567 @@ func (za *ZAuthArgsEntry) ReadCounter(zc common.ZAuthCommonComp) (int64, error)
 	}
 }
 
+// readEntry reads all fields of entry z from user. Current values of z are used as defaults.
+func readEntry(ze ZAuthArgsEntryInput, zc common.ZAuthCommonComp, z *zauth.ZAuth) error {
+	sec, err := ze.ReadSecret(zc, z.Secret)
+	if err != nil {
+		return fmt.Errorf("An error occured while reading secret: %v", err)
+	}
+	z.Secret = sec
+
+	iss, err := ze.ReadIssuer(zc, z.Issuer)
+	if err != nil {
+		return fmt.Errorf("An error occured while reading issuer: %v", err)
+	}
+
+	id, err := ze.ReadIdentifier(zc, common.LabelIdentifier(z.Label))
+	if err != nil {
+		return fmt.Errorf("An error occured while reading identifier: %v", err)
+	}
+	z.Issuer = iss
+	z.Label = fmt.Sprintf("%s:%s", z.Issuer, id)
+
+	tp, err := ze.ReadType(zc, defaultString(z.Type, zauth.DefaultType))
+	if err != nil {
+		return fmt.Errorf("An error occured while reading type: %v", err)
+	}
+	z.Type = tp
+
+	// Steam Guard codes have fixed digits, algorithm and period
+	if z.Type == "steam" {
+		z.Digits = zauth.SteamDigits
+		z.Algorithm = zauth.DefaultAlgo
+		z.Period = zauth.DefaultPeriod
+		z.Counter = 0
+		return nil
+	}
+
+	dt, err := ze.ReadDigits(zc, defaultInt(z.Digits, zauth.DefaultDigits))
+	if err != nil {
+		return fmt.Errorf("An error occured while reading digits: %v", err)
+	}
+	z.Digits = dt
+
+	if z.Type == "totp" {
+		algo, err := ze.ReadAlgorithm(zc, defaultString(z.Algorithm, zauth.DefaultAlgo))
+		if err != nil {
+			return fmt.Errorf("An error occured while reading algorithm: %v", err)
+		}
+		z.Algorithm = algo
+
+		pd, err := ze.ReadPeriod(zc, defaultInt64(z.Period, zauth.DefaultPeriod))
+		if err != nil {
+			return fmt.Errorf("An error occured while reading period: %v", err)
+		}
+		z.Period = pd
+
+	} else {
+		// algorithm is not prompted for HOTP entries. Existing algorithm (eg: of imported entries) is kept
+		z.Algorithm = defaultString(z.Algorithm, zauth.DefaultAlgo)
+
+		ctr, err := ze.ReadCounter(zc, z.Counter)
+		if err != nil {
+			return fmt.Errorf("An error occured while reading counter: %v", err)
+		}
+		z.Counter = ctr
+	}
+
+	return nil
+}
+
+// selectEntry returns index of the single entry in z matching query q.
+// If q is empty, entries are listed and query is read from user.
+func selectEntry(zc common.ZAuthCommonComp, z []zauth.ZAuth, q string) (int, error) {
+	idx, err := selectEntries(zc, z, q, false)
+	if err != nil {
+		return 0, err
+	}
+	return idx[0], nil
+}
+
+// selectEntries returns indexes of entries in z matching query q.
+// Glob pattern queries may select several entries if multi is true. Other queries must match a single entry.
+// If q is empty, entries are listed and query is read from user.
+func selectEntries(zc common.ZAuthCommonComp, z []zauth.ZAuth, q string, multi bool) ([]int, error) {
+	if q == "" {
+		printEntries(z, nil)
+		fmt.Print("\nSelect entry (index/id/issuer/label): ")
+		in, err := zc.UserInput()
+		if err != nil {
+			return nil, err
+		}
+		q = strings.TrimSpace(in)
+	}
+
+	idx := common.FindEntries(z, q)
+	if len(idx) == 0 {
+		return nil, fmt.Errorf("no entry matches %q", q)
+	}
+
+	if len(idx) > 1 && !(multi && common.IsPattern(q)) {
+		printEntries(z, idx)
+		return nil, fmt.Errorf("%d entries match %q. please be more specific", len(idx), q)
+	}
+
+	return idx, nil
+}
+
+// stdinIsTerminal reports whether stdin is a terminal, from which missing entry fields can be prompted for.
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// newClipboard returns clipboard used by code -copy.
+var newClipboard = clipboard.Detect
+
+// copyOtp copies OTP o of entry z to clipboard cb and waits until clipboard is cleared.
+// Clipboard is cleared after timeout clear, when TOTP period rolls over (whichever comes first), or on interrupt.
+// If clear is 0, clipboard is not cleared.
+func copyOtp(cb clipboard.Clipboard, z *zauth.ZAuth, o *zauth.ZAuthOtp, clear time.Duration) error {
+	err := cb.Copy(o.Otp)
+	if err != nil {
+		return err
+	}
+
+	if clear <= 0 {
+		fmt.Fprintf(flag.CommandLine.Output(), "OTP of (%s) %s copied to clipboard (%s)\n", z.Issuer, z.Label, cb.Name())
+		return nil
+	}
+
+	if r := time.Duration(o.Remaining) * time.Second; r > 0 && r < clear {
+		clear = r
+	}
+
+	fmt.Fprintf(flag.CommandLine.Output(), "OTP of (%s) %s copied to clipboard (%s). Clearing in %v (Ctrl+C to clear now)\n", z.Issuer, z.Label, cb.Name(), clear)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case <-time.After(clear):
+	case <-sig:
+	}
+
+	return cb.Clear()
+}
+
+// updateVault locks store st, reads it's vault again and writes it after applying change f.
+// Entries are selected (and changes prompted for) before updateVault is called, so that the lock is not held
+// while waiting for user input. Selected entries must be found again by ID, as vault may have been changed meanwhile.
+func updateVault(st *common.Store, f func(v *zauth.ZAuthVault) error) error {
+	return writeVault(st, st.Write, f)
+}
+
+// writeVault is updateVault, writing changed vault with w (eg: Store.WritePurge).
+func writeVault(st *common.Store, w func(v *zauth.ZAuthVault) error, f func(v *zauth.ZAuthVault) error) error {
+	err := st.Lock()
+	if err != nil {
+		return err
+	}
+	defer st.Unlock()
+
+	v, err := st.Read()
+	if err != nil {
+		return err
+	}
+
+	err = f(v)
+	if err != nil {
+		return err
+	}
+	return w(v)
+}
+
+// deletedEntries returns entries of deleted entries d, so that they can be selected like entries.
+func deletedEntries(d []zauth.ZAuthDeleted) []zauth.ZAuth {
+	z := make([]zauth.ZAuth, len(d))
+	for i := range d {
+		z[i] = d[i].ZAuth
+	}
+	return z
+}
+
+// entryIndex returns index of entry having ID id in z, or -1 if there is none.
+func entryIndex(z []zauth.ZAuth, id string) int {
+	for i := range z {
+		if z[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// errEntryChanged returns error reported when selected entry z is no longer found, once vault is read again.
+func errEntryChanged(z *zauth.ZAuth) error {
+	return fmt.Errorf("entry (%s) %s was changed by another process, please try again", z.Issuer, z.Label)
+}
+
+// findEntry returns index of the single entry in z matching query q, without prompting user.
+// If several entries match, they are printed to error output.
+func findEntry(z []zauth.ZAuth, q string) (int, error) {
+	if strings.TrimSpace(q) == "" {
+		return 0, fmt.Errorf("entry query cannot be empty")
+	}
+
+	idx := common.FindEntries(z, q)
+	if len(idx) == 0 {
+		return 0, fmt.Errorf("no entry matches %q", q)
+	}
+
+	if len(idx) > 1 {
+		for _, i := range idx {
+			fmt.Fprintf(flag.CommandLine.Output(), "[%d] %s (%s) %s\n", i+1, zauth.ShortID(z[i].ID), z[i].Issuer, z[i].Label)
+		}
+		return 0, fmt.Errorf("%d entries match %q. please be more specific", len(idx), q)
+	}
+
+	return idx[0], nil
+}
+
+// readLines reads non-empty lines from user until EOF. Lines starting with # are ignored.
+func readLines(zc common.ZAuthCommonComp) ([]string, error) {
+	lines := make([]string, 0)
+	for {
+		l, err := zc.UserInput()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return lines, nil
+			}
+			return nil, err
+		}
+
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "#") {
+			lines = append(lines, l)
+		}
+	}
+}
+
+// confirm prints prompt p and reports whether user answered yes.
+func confirm(zc common.ZAuthCommonComp, p string) (bool, error) {
+	fmt.Print(p)
+	in, err := zc.UserInput()
+	if err != nil {
+		return false, err
+	}
+
+	in = strings.ToLower(strings.TrimSpace(in))
+	return in == "y" || in == "yes", nil
+}
+
+// printEntries prints entries z at indexes idx. If idx is nil, all entries are printed.
+func printEntries(z []zauth.ZAuth, idx []int) {
+	if idx == nil {
+		idx = make([]int, len(z))
+		for i := range z {
+			idx[i] = i
+		}
+	}
+
+	for _, i := range idx {
+		l := z[i]
+		out := fmt.Sprintf("[%d] %s (%s) %s (%s)", i+1, zauth.ShortID(l.ID), l.Issuer, l.Label, strings.ToUpper(l.Type))
+		for _, t := range l.Tags {
+			out += " #" + t
+		}
+		fmt.Println(out)
+	}
+}
+
+func requiredOrDefault(def string) string {
+	if def == "" {
+		return "required"
+	}
+	return "default: " + def
+}
+
+func defaultString(v string, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func defaultInt(v int, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func defaultInt64(v int64, def int64) int64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// vaultPasswordReader returns PasswordReader for vault password.
+// Password is read from file f if set, else from PasswordEnv if set, else it is prompted for with zc.
+func vaultPasswordReader(zc common.PasswordReader, f string) (common.PasswordReader, error) {
+	if f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		pwd := strings.TrimRight(string(b), "\r\n")
+		if pwd == "" {
+			return nil, fmt.Errorf("%s: password cannot be empty", f)
+		}
+		return common.StaticPassword(pwd), nil
+	}
+
+	if pwd := os.Getenv(common.PasswordEnv); pwd != "" {
+		return common.StaticPassword(pwd), nil
+	}
+	return zc, nil
+}
+
+// parseInterspersed parses flags of f found anywhere in args and returns remaining positional arguments.
+// This allows flags to follow positional arguments (eg: zauth code GitHub --next).
+func parseInterspersed(f *flag.FlagSet, args []string) []string {
+	pos := make([]string, 0)
+	for {
+		f.Parse(args)
+		args = f.Args()
+		if len(args) == 0 {
+			return pos
+		}
+
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
 func printUsage(t string, f *flag.FlagSet) {
 	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s %s [OPTIONS]\n\nOPTIONS:\n", os.Args[0], t)
 	f.PrintDefaults()
 }
 
-func printZAuthOtpTable() error {
-	tbl := table.New("ISSUER", "IDENTIFIER", "TYPE", "OTP", "REMAINING")
+// listOptions are options of codes and entry listings.
+type listOptions struct {
+	query   string // entries filter (see common.FilterEntries). All entries are listed if empty
+	tag     string // only list entries having tag (see common.FilterTag)
+	sort    string // sort order (see common.SortEntries)
+	output  string // output format (see SupportedOutputFormats)
+	secrets bool   // include secrets in machine-readable output
+}
+
+// listEntries returns indexes of entries in z matching query and tag of lo, sorted by sort order of lo.
+// An error is returned if query or tag matches no entry.
+func listEntries(z []zauth.ZAuth, lo listOptions) ([]int, error) {
+	idx := common.FilterEntries(z, lo.query)
+	if len(idx) == 0 && lo.query != "" {
+		return nil, fmt.Errorf("no entry matches %q (see -h for available commands)", lo.query)
+	}
+
+	idx = common.FilterTag(z, idx, lo.tag)
+	if len(idx) == 0 && lo.tag != "" {
+		return nil, fmt.Errorf("no entry has tag %q", lo.tag)
+	}
+
+	err := common.SortEntries(z, idx, lo.sort)
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func printZAuthOtpTable(st *common.Store, lo listOptions) error {
+	tbl := table.New("ID", "ISSUER", "IDENTIFIER", "TYPE", "OTP", "REMAINING")
 
 	tbl.WithPadding(5)
 	tbl.WithWidthFunc(runewidth.StringWidth)
 
-	zl, err := common.ReadZAuthJson()
+	zl, err := st.ReadZAuthJson()
 	if err != nil {
 		msg := fmt.Sprintf("An error occured while reading entries: %v", err)
 		fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
 		return fmt.Errorf(msg)
 	}
 
-	for _, z := range zl {
-		otp, err := otp.GenerateOTP(&z)
+	idx, err := listEntries(zl, lo)
+	if err != nil {
+		msg := err.Error()
+		fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+		return fmt.Errorf(msg)
+	}
+
+	out, sec := lo.output, lo.secrets
+	var cols []string
+	rows := make([][]interface{}, 0, len(idx))
+
+	hotp := false
+	failed := 0
+	for _, i := range idx {
+		z := &zl[i]
+
+		// HOTP codes are only generated on request, as generating a code consumes the counter
+		if strings.ToLower(z.Type) == "hotp" {
+			hotp = true
+			if out == OutputTable {
+				tbl.AddRow(zauth.ShortID(z.ID), z.Issuer, common.LabelIdentifier(z.Label), strings.ToUpper(z.Type), "press to generate", "-")
+			} else {
+				var row []interface{}
+				cols, row = otpRecord(z, nil, nil, sec)
+				rows = append(rows, row)
+			}
+			continue
+		}
+
+		// entries whose code cannot be generated are still listed, so that no entry is missing from output
+		otp, err := otp.GenerateOTP(z)
 		if err != nil {
 			fmt.Fprintf(flag.CommandLine.Output(), "An error occured while generating OTP for %s: %v\n", z.Label, err)
+			failed++
 		}
 
-		var lbl string
-
-		if strings.Contains(z.Label, ":") {
-			lbl = strings.Split(z.Label, ":")[1]
+		if out == OutputTable {
+			if err != nil {
+				tbl.AddRow(zauth.ShortID(z.ID), z.Issuer, common.LabelIdentifier(z.Label), strings.ToUpper(z.Type), "error", "-")
+			} else {
+				tbl.AddRow(zauth.ShortID(z.ID), z.Issuer, common.LabelIdentifier(z.Label), strings.ToUpper(z.Type), otp.Otp, otp.Remaining)
+			}
 		} else {
-			lbl = z.Label
+			var row []interface{}
+			cols, row = otpRecord(z, otp, err, sec)
+			rows = append(rows, row)
+		}
+	}
+
+	if out != OutputTable {
+		if cols == nil {
+			cols, _ = otpRecord(&zauth.ZAuth{}, nil, nil, sec)
 		}
 
-		tbl.AddRow(z.Issuer, lbl, strings.ToUpper(z.Type), otp.Otp, otp.Remaining)
+		err = writeRecords(os.Stdout, out, cols, rows)
+		if err != nil {
+			msg := fmt.Sprintf("An error occured while printing entries: %v", err)
+			fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+			return fmt.Errorf(msg)
+		}
+	} else {
+		tbl.Print()
+
+		if hotp {
+			fmt.Println("\nGenerate HOTP codes with: zauth code <entry> --next")
+		}
 	}
-	tbl.Print()
 
+	if failed > 0 {
+		return fmt.Errorf("unable to generate OTP for %d entries", failed)
+	}
 	return nil
 }
+
+// otpRecord returns columns and values of OTP o of entry z for machine-readable output.
+// o is nil for HOTP entries whose code is not generated, and for entries whose code generation failed with gerr.
+// Secret is included if sec is true.
+func otpRecord(z *zauth.ZAuth, o *zauth.ZAuthOtp, gerr error, sec bool) ([]string, []interface{}) {
+	cols := []string{"id", "issuer", "identifier", "label", "type", "tags", "otp", "remaining", "error"}
+	row := []interface{}{z.ID, z.Issuer, common.LabelIdentifier(z.Label), z.Label, strings.ToLower(z.Type), common.NormalizeTags(z.Tags), nil, nil, nil}
+	if o != nil {
+		row[6] = o.Otp
+		row[7] = o.Remaining
+	}
+	if gerr != nil {
+		row[8] = gerr.Error()
+	}
+
+	if sec {
+		cols = append(cols, "secret")
+		row = append(row, z.Secret)
+	}
+	return cols, row
+}
+
+// entryRecord returns columns and values of all fields of entry z (without code) for machine-readable output.
+// Secret is included if sec is true.
+func entryRecord(z *zauth.ZAuth, sec bool) ([]string, []interface{}) {
+	meta := z.Meta
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+
+	cols := []string{"id", "issuer", "identifier", "label", "type", "tags", "digits", "algorithm", "period", "counter", "url", "notes", "meta", "last_used", "used_frequency"}
+	row := []interface{}{z.ID, z.Issuer, common.LabelIdentifier(z.Label), z.Label, strings.ToLower(z.Type), common.NormalizeTags(z.Tags), z.Digits, z.Algorithm, z.Period, z.Counter, z.URL, z.Notes, meta, common.LastUsed(z), common.UsedFrequency(z)}
+
+	if sec {
+		cols = append(cols, "secret")
+		row = append(row, z.Secret)
+	}
+	return cols, row
+}
+
+// printEntry prints details of entry z (without secret).
+func printEntry(z *zauth.ZAuth) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := func(k string, v interface{}) {
+		fmt.Fprintf(w, "%s:\t%v\n", k, v)
+	}
+
+	row("ID", z.ID)
+	row("Issuer", z.Issuer)
+	row("Identifier", common.LabelIdentifier(z.Label))
+	row("Type", strings.ToUpper(z.Type))
+	row("Digits", z.Digits)
+	if strings.ToLower(z.Type) == "hotp" {
+		row("Counter", z.Counter)
+	} else {
+		row("Algorithm", strings.ToUpper(defaultString(z.Algorithm, zauth.DefaultAlgo)))
+		row("Period", z.Period)
+	}
+	row("Tags", strings.Join(z.Tags, ", "))
+	row("URL", z.URL)
+
+	keys := make([]string, 0, len(z.Meta))
+	for k := range z.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		row(k, z.Meta[k])
+	}
+
+	if lu := common.LastUsed(z); lu > 0 {
+		row("Last used", time.Unix(0, lu*int64(time.Millisecond)).Format("2006-01-02 15:04"))
+	} else {
+		row("Last used", "never")
+	}
+	row("Used", fmt.Sprintf("%d times", common.UsedFrequency(z)))
+	w.Flush()
+
+	if z.Notes != "" {
+		fmt.Printf("\nNotes:\n%s\n", z.Notes)
+	}
+}
+
+// writeEntries writes entries z at indexes idx (without codes) in machine-readable format o to w. If idx is nil, all entries are written.
+// Secrets are included if sec is true.
+func writeEntries(w io.Writer, o string, z []zauth.ZAuth, idx []int, sec bool) error {
+	if idx == nil {
+		idx = make([]int, len(z))
+		for i := range z {
+			idx[i] = i
+		}
+	}
+
+	cols := []string{"index", "id", "issuer", "identifier", "label", "type", "tags", "digits", "algorithm", "period", "counter"}
+	if sec {
+		cols = append(cols, "secret")
+	}
+
+	rows := make([][]interface{}, 0, len(idx))
+	for _, i := range idx {
+		l := z[i]
+		r := []interface{}{i + 1, l.ID, l.Issuer, common.LabelIdentifier(l.Label), l.Label, strings.ToLower(l.Type), common.NormalizeTags(l.Tags), l.Digits, l.Algorithm, l.Period, l.Counter}
+		if sec {
+			r = append(r, l.Secret)
+		}
+		rows = append(rows, r)
+	}
+
+	return writeRecords(w, o, cols, rows)
+}
@@ -1,10 +1,20 @@
 package args
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/grijul/zauth/internal/clipboard"
+	"github.com/grijul/zauth/internal/common"
 	"github.com/grijul/zauth/internal/zauth"
 	"github.com/grijul/zauth/test"
 )
@@ -16,9 +26,17 @@ var zc *zauthCommonTest
 var isErroredPassword bool
 var isEmptyPassword bool
 
+// userInputs are returned by UserInput in order.
+// Once exhausted, io.EOF is returned if isInputEOF is true. Else "test" is returned.
+var userInputs []string
+var isInputEOF bool
+
+// onUserInput is called by UserInput before returning input, if set (eg: to act as another process while prompting).
+var onUserInput func()
+
 func init() {
-	zauth.ZAuthJson = test.TestZAuthJson
-	zauth.ZAuthJsonDir = test.TestZAuthJsonDir
+	os.Setenv(common.DirEnv, test.TestZAuthJsonDir)
+	stdinIsTerminal = func() bool { return false }
 }
 
 func TestParseNoSubcommandArgs(t *testing.T) {
@@ -43,6 +61,24 @@ func TestParseNoSubcommandArgs(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// data directory from flag
+	os.Args = []string{"zauth", "-dir", filepath.Join(test.TestZAuthJsonDir, "other")}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when no zauth json is found in data directory")
+	}
+
+	os.Args = []string{"zauth", "-dir", filepath.Join(test.TestZAuthJsonDir, "other"), "import", "-type=andotp", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(test.TestZAuthJsonDir, "other", common.ZAuthJsonName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// invalid subcommand
 	// error: no file found
 	os.Args = []string{"zauth", "testxyz"}
@@ -53,6 +89,72 @@ func TestParseNoSubcommandArgs(t *testing.T) {
 
 }
 
+func TestParsePasswordArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+	defer resetPasswordFlags()
+
+	// vault password is never prompted for when it is given non-interactively
+	isErroredPassword = true
+
+	os.Args = []string{"zauth", "import", "-type=andotp", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
+	err := ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when password cannot be read")
+	}
+
+	// password from environment
+	os.Setenv(common.PasswordEnv, test.AndOtpAccountsEncPassword)
+	defer os.Unsetenv(common.PasswordEnv)
+
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "-output", "json"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(common.PasswordEnv, "wrong")
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when password is wrong")
+	}
+
+	// password file takes precedence over environment
+	pf := filepath.Join(test.TestZAuthJsonDir, "password")
+	err = os.WriteFile(pf, []byte(test.AndOtpAccountsEncPassword+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "-password-file", pf, "entry", "-list"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "-password-file", filepath.Join(test.TestZAuthJsonDir, "missing"), "entry", "-list"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when password file does not exist")
+	}
+
+	err = os.WriteFile(pf, []byte("\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "-password-file", pf, "entry", "-list"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when password file is empty")
+	}
+}
+
 func TestParseImportArgs(t *testing.T) {
 	defer test.RemoveTestFiles()
 
@@ -100,7 +202,6 @@ func TestParseImportArgs(t *testing.T) {
 	}
 
 	// invalid type
-	zauth.ZAuthJson = test.TestZAuthJson
 	os.Args = []string{"zauth", "import", "-type=invalid", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
 	err = ParseArgs(zc)
 	if err == nil {
@@ -238,37 +339,995 @@ func TestParseEntryArgs(t *testing.T) {
 	}
 
 	//entry edit
-	os.Args = []string{"zauth", "entry", "-edit"}
+	// no matching entry
+	os.Args = []string{"zauth", "entry", "-edit", "xyz"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when no entry matches")
+	}
+
+	// several matching entries
+	os.Args = []string{"zauth", "entry", "-edit", "org"}
 	err = ParseArgs(zc)
 	if err == nil {
-		t.Fatal("expected test to fail since functionality is not yet implemented")
+		t.Fatal("expected test to fail when several entries match")
+	}
+
+	// edit by index. empty inputs keep current values
+	userInputs = []string{"", "New Org", "", "", "8", "", ""}
+	os.Args = []string{"zauth", "entry", "-edit", "1"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lst[0].Issuer != "New Org" || lst[0].Label != "New Org:HelloWorld123" || lst[0].Digits != 8 || lst[0].Secret != "JBSWY3DPK5XXE3DEGEZDGCQ=" {
+		t.Fatal("unexpected entry after edit: ", lst[0])
+	}
+
+	// edit by selection prompt
+	userInputs = []string{"helloworld456", "", "", "Someone", "", "", "", ""}
+	os.Args = []string{"zauth", "entry", "-edit"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err = common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lst[1].Label != "Another Org:Someone" {
+		t.Fatal("unexpected entry after edit: ", lst[1])
 	}
 
 	//entry delete
-	os.Args = []string{"zauth", "entry", "-delete"}
+	// nothing to restore
+	os.Args = []string{"zauth", "entry", "-restore"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when trash is empty")
+	}
+
+	// deletion not confirmed
+	os.Args = []string{"zauth", "entry", "-delete", "1"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntryCount(t, 2)
+
+	// deletion confirmed
+	userInputs = []string{"y"}
+	os.Args = []string{"zauth", "entry", "-delete", "new org"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntryCount(t, 1)
+
+	// pattern without confirmation
+	os.Args = []string{"zauth", "entry", "-delete", "-force", "*org*"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntryCount(t, 0)
+
+	// restore by pattern
+	os.Args = []string{"zauth", "entry", "-restore", "*"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntryCount(t, 2)
+
+	//entry purge
+	os.Args = []string{"zauth", "entry", "-delete", "-force", "1"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// purge not confirmed
+	os.Args = []string{"zauth", "entry", "-purge", "*"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := common.NewStore(test.TestZAuthJsonDir, zc).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Trash) != 1 {
+		t.Fatal("expected deleted entries count: 1. received: ", len(v.Trash))
+	}
+
+	// purge confirmed. purged entries cannot be restored
+	userInputs = []string{"y"}
+	os.Args = []string{"zauth", "entry", "-purge", "*"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "entry", "-restore", "*"}
 	err = ParseArgs(zc)
 	if err == nil {
-		t.Fatal("expected test to fail since functionality is not yet implemented")
+		t.Fatal("expected test to fail when trash is empty")
+	}
+	assertEntryCount(t, 1)
+
+	_, err = os.Stat(test.TestZAuthJson + common.BackupSuffix)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected backup to be removed by purge: ", err)
 	}
 }
 
-func (*zauthCommonTest) ReadPassword() (string, error) {
-	if isEmptyPassword {
-		return "", nil
+func TestParseEntryLockArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	tm := common.LockTimeout
+	common.LockTimeout = 100 * time.Millisecond
+	defer func() { common.LockTimeout = tm }()
+	defer func() { onUserInput = nil }()
+
+	os.Args = []string{"zauth", "import", "-type=andotp", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if isErroredPassword {
-		return "", fmt.Errorf("password error")
+	// store is not locked while prompting, so that other processes are not blocked
+	var lerr error
+	other := common.NewStore(test.TestZAuthJsonDir, zc)
+	onUserInput = func() {
+		if lerr = other.Lock(); lerr == nil {
+			other.Unlock()
+		}
 	}
 
-	return test.AndOtpAccountsEncPassword, nil
+	for _, tc := range []struct {
+		args []string
+		in   []string
+	}{
+		{[]string{"-edit", "1"}, []string{"", "", "", "", "", "", ""}},
+		{[]string{"-delete", "1"}, []string{"y"}},
+		{[]string{"-restore"}, []string{"1"}},
+		{[]string{"-delete", "1"}, []string{"y"}},
+		{[]string{"-purge"}, []string{"1", "y"}},
+	} {
+		userInputs = tc.in
+		os.Args = append([]string{"zauth", "entry"}, tc.args...)
+		err = ParseArgs(zc)
+		if err != nil {
+			t.Fatal(tc.args, err)
+		}
+		if lerr != nil {
+			t.Fatal(tc.args, lerr)
+		}
+	}
+	assertEntryCount(t, 1)
+
+	// entry deleted by another process while prompting
+	onUserInput = func() {
+		onUserInput = nil
+		err := other.WriteZAuthJson(nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	userInputs = []string{"", "", "", "", "", "", ""}
+	os.Args = []string{"zauth", "entry", "-edit", "1"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when entry is deleted while editing it")
+	}
+	assertEntryCount(t, 0)
 }
 
-func resetPasswordFlags() {
-	isEmptyPassword = false
-	isErroredPassword = false
+func TestReadEntryHotp(t *testing.T) {
+	defer func() { userInputs = nil }()
+
+	// empty inputs keep current values, including algorithm which is not prompted for HOTP entries
+	for _, algo := range []string{"sha256", ""} {
+		userInputs = []string{"", "", "", "", "", ""}
+		z := &zauth.ZAuth{Secret: "JBSWY3DPEHPK3PXP", Issuer: "SomeOrg", Label: "SomeOrg:a@example.com", Type: "hotp", Digits: 6, Algorithm: algo, Counter: 3}
+		err := readEntry(&ZAuthArgsEntry{}, zc, z)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exp := defaultString(algo, zauth.DefaultAlgo)
+		if z.Algorithm != exp || z.Counter != 3 {
+			t.Fatalf("expected algorithm %s and counter 3. received: %s, %d", exp, z.Algorithm, z.Counter)
+		}
+	}
 }
 
-func (*zauthCommonTest) UserInput() (string, error) {
+func TestParseEntryUriArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	// single uri
+	os.Args = []string{"zauth", "entry", "-new", "-uri", "otpauth://totp/SomeOrg:test@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA1&digits=6&period=30"}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntryCount(t, 1)
+
+	// invalid uri
+	os.Args = []string{"zauth", "entry", "-new", "-uri", "https://example.com"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when uri is invalid")
+	}
+
+	// uris from stdin
+	userInputs = []string{
+		"# comment\n",
+		"otpauth://totp/SomeOrg:a@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA256&digits=8&period=60\n",
+		"\n",
+		"otpauth://hotp/SomeOrg:b@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA1&digits=6&counter=3",
+	}
+	isInputEOF = true
+	defer func() { isInputEOF = false }()
+
+	os.Args = []string{"zauth", "entry", "-new", "-uri", "-"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntryCount(t, 3)
+
+	// show uri and qr code
+	os.Args = []string{"zauth", "entry", "-show-uri", "-qr", "b@example.com"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// empty stdin
+	os.Args = []string{"zauth", "entry", "-new", "-uri", "-"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when no uri is read")
+	}
+	assertEntryCount(t, 3)
+
+	// uris piped to stdin of an existing vault: stdin is not used for vault password
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	_, err = io.WriteString(w, "otpauth://totp/OtherOrg:c@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=OtherOrg\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	os.Setenv(common.PasswordEnv, test.AndOtpAccountsEncPassword)
+	defer os.Unsetenv(common.PasswordEnv)
+
+	os.Args = []string{"zauth", "entry", "-new", "-uri", "-"}
+	err = ParseArgs(&common.ZAuthCommon{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEntryCount(t, 4)
+}
+
+func TestParseEntryFlagArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	// all fields supplied
+	os.Args = []string{"zauth", "entry", "-new", "-secret", "JBSWY3DPEHPK3PXP", "-issuer", "SomeOrg", "-account", "a@example.com", "-type", "totp", "-digits", "8", "-algorithm", "sha256", "-period", "60"}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lst[0].Label != "SomeOrg:a@example.com" || lst[0].Digits != 8 || lst[0].Algorithm != "sha256" || lst[0].Period != 60 {
+		t.Fatal("unexpected entry: ", lst[0])
+	}
+
+	// fields not used by entry type are rejected
+	for _, f := range [][]string{{"-counter", "3"}, {"-type", "hotp", "-algorithm", "sha256"}, {"-type", "hotp", "-period", "60"}, {"-type", "steam", "-digits", "6"}, {"-type", "steam", "-period", "60"}, {"-type", "steam", "-algorithm", "sha1"}} {
+		os.Args = append([]string{"zauth", "entry", "-new", "-secret", "JBSWY3DPEHPK3PXP", "-issuer", "SomeOrg", "-account", "b@example.com"}, f...)
+		err = ParseArgs(zc)
+		if err == nil {
+			t.Fatalf("expected test to fail when %v is used", f)
+		}
+	}
+	assertEntryCount(t, 1)
+
+	// invalid fields are not prompted again
+	for _, f := range [][]string{{"-digits", "11"}, {"-type", "xyz"}, {"-algorithm", "md5"}, {"-period", "0"}, {"-secret", "xyz"}, {"-issuer", " "}} {
+		os.Args = append([]string{"zauth", "entry", "-new", "-secret", "JBSWY3DPEHPK3PXP", "-issuer", "SomeOrg", "-account", "b@example.com"}, f...)
+		err = ParseArgs(zc)
+		if err == nil {
+			t.Fatalf("expected test to fail when %s is invalid", f[0])
+		}
+	}
+	assertEntryCount(t, 1)
+
+	// missing required fields are reported when stdin is not a terminal
+	os.Args = []string{"zauth", "entry", "-new", "-secret", "JBSWY3DPEHPK3PXP", "-type", "hotp", "-counter", "3"}
+	err = ParseArgs(zc)
+	if err == nil || !strings.Contains(err.Error(), "-issuer, -account") {
+		t.Fatal("expected test to fail when required fields are missing. received: ", err)
+	}
+	assertEntryCount(t, 1)
+
+	// else they are prompted. Optional fields take default values
+	stdinIsTerminal = func() bool { return true }
+	defer func() { stdinIsTerminal = func() bool { return false } }()
+
+	userInputs = []string{"Another Org", "c@example.com"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err = common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lst[1].Label != "Another Org:c@example.com" || lst[1].Type != "hotp" || lst[1].Counter != 3 || lst[1].Digits != zauth.DefaultDigits {
+		t.Fatal("unexpected entry: ", lst[1])
+	}
+
+	// entry fields are rejected by operations which do not use them
+	for _, a := range [][]string{
+		{"-edit", "1", "-issuer", "Other"},
+		{"-delete", "1", "-secret", "JBSWY3DPEHPK3PXP"},
+		{"-list", "-tags", "work"},
+		{"-show", "1", "-notes", "x"},
+		{"-new", "-uri", "otpauth://totp/SomeOrg:d@example.com?secret=JBSWY3DPEHPK3PXP", "-digits", "8"},
+	} {
+		os.Args = append([]string{"zauth", "entry"}, a...)
+		err = ParseArgs(zc)
+		if err == nil {
+			t.Fatal("expected test to fail when entry fields are not used: ", a)
+		}
+	}
+	assertEntryCount(t, 2)
+}
+
+func TestParseCodeArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	userInputs = []string{
+		"otpauth://totp/SomeOrg:a@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA1&digits=6&period=30",
+		"otpauth://hotp/SomeOrg:b@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA1&digits=6&counter=3",
+	}
+	isInputEOF = true
+	defer func() { isInputEOF = false }()
+
+	os.Args = []string{"zauth", "entry", "-new", "-uri", "-"}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// totp code. stdout only holds the code, even without terminal (eg: in scripts)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	os.Setenv(common.PasswordEnv, test.AndOtpAccountsEncPassword)
+	defer os.Unsetenv(common.PasswordEnv)
+
+	os.Args = []string{"zauth", "code", "a@example.com"}
+	out, err := captureStdout(t, func() error { return ParseArgs(&common.ZAuthCommon{}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[0-9]{6}\n$`).MatchString(out) {
+		t.Fatalf("expected only code on stdout. received: %q", out)
+	}
+
+	os.Stdin = stdin
+	os.Unsetenv(common.PasswordEnv)
+
+	// entry selected by ID
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "code", zauth.ShortID(lst[0].ID)}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fuzzy match with remaining seconds
+	os.Args = []string{"zauth", "code", "-remaining", "a@exmpl"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// copy to clipboard. clipboard is cleared after timeout
+	b := &bytes.Buffer{}
+	newClipboard = func() clipboard.Clipboard { return clipboard.NewOSC52(b, false) }
+	defer func() { newClipboard = clipboard.Detect }()
+
+	os.Args = []string{"zauth", "code", "a@example.com", "-copy", "-clear", "10ms"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "\x1b]52;c;") || !strings.HasSuffix(b.String(), "\x1b]52;c;!\a") {
+		t.Fatalf("unexpected clipboard output: %q", b.String())
+	}
+
+	// no matching entry
+	os.Args = []string{"zauth", "code", "xyz"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when no entry matches")
+	}
+
+	// several matching entries
+	os.Args = []string{"zauth", "code", "example"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when several entries match")
+	}
+
+	// entry is never prompted
+	os.Args = []string{"zauth", "code"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when query is empty")
+	}
+
+	// hotp code requires --next
+	os.Args = []string{"zauth", "code", "b@example.com"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when --next is not given for hotp entry")
+	}
+
+	// flag following query
+	os.Args = []string{"zauth", "code", "b@example.com", "--next"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flag preceding query
+	os.Args = []string{"zauth", "code", "-next", "b@example.com"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err = common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lst[1].Counter != 5 {
+		t.Fatalf("expected counter: 5. received: %d", lst[1].Counter)
+	}
+
+	// hotp entries are not generated in table
+	os.Args = []string{"zauth"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err = common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lst[1].Counter != 5 {
+		t.Fatalf("expected counter: 5. received: %d", lst[1].Counter)
+	}
+}
+
+func TestParseVaultArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	uri := "otpauth://totp/SomeOrg:test@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA1&digits=6&period=30"
+
+	// vault must be created first
+	os.Args = []string{"zauth", "--vault", "work", "entry", "-new", "-uri", uri}
+	err := ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when vault does not exist")
+	}
+
+	os.Args = []string{"zauth", "vault", "create", "work"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "--vault", "work", "entry", "-new", "-uri", uri}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "--vault", "work", "entry", "-list"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// default vault is unchanged
+	os.Args = []string{"zauth", "entry", "-list"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when default vault does not exist")
+	}
+
+	os.Args = []string{"zauth", "vault", "rename", "work", "team"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "--vault", "team", "vault", "list"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// removal not confirmed
+	os.Args = []string{"zauth", "vault", "remove", "team"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userInputs = []string{"y"}
+	os.Args = []string{"zauth", "vault", "remove", "team"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "--vault", "team"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when vault is removed")
+	}
+
+	os.Args = []string{"zauth", "vault", "remove", "team"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when vault does not exist")
+	}
+}
+
+func TestParseListArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	os.Args = []string{"zauth", "import", "-type=andotp", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range [][]string{
+		{"zauth", "some", "org"},
+		{"zauth", "-sort", "issuer", "org"},
+		{"zauth", "-sort", "frequency"},
+		{"zauth", "entry", "-list", "anothr"},
+		{"zauth", "-sort", "recent", "entry", "-list"},
+	} {
+		os.Args = a
+		err = ParseArgs(zc)
+		if err != nil {
+			t.Fatal(a, err)
+		}
+	}
+
+	os.Args = []string{"zauth", "entry", "-list", "xyz"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when no entry matches")
+	}
+
+	os.Args = []string{"zauth", "-sort", "xyz"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when sort order is invalid")
+	}
+
+	// mistyped commands are reported, as they match no entry
+	os.Args = []string{"zauth", "exprot"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when command is invalid")
+	}
+
+	// usage is recorded by code
+	os.Args = []string{"zauth", "code", "another"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.UsedFrequency(&lst[0]) != 0 || common.UsedFrequency(&lst[1]) != 1 || common.LastUsed(&lst[1]) <= common.LastUsed(&lst[0]) {
+		t.Fatalf("unexpected usage: %v, %v", lst[0].Misc, lst[1].Misc)
+	}
+}
+
+func TestParseSteamArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	// digits, algorithm and period are not prompted
+	userInputs = []string{"JBSWY3DPEHPK3PXP", "Steam", "alice", "steam"}
+	isInputEOF = true
+	defer func() { isInputEOF = false }()
+
+	os.Args = []string{"zauth", "entry", "-new"}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "entry", "-new", "-uri", "otpauth://steam/Steam:bob?secret=JBSWY3DPEHPK3PXP&issuer=Steam"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, z := range lst {
+		if z.Type != "steam" || z.Digits != 5 || z.Period != 30 {
+			t.Fatalf("unexpected entry: %+v", z)
+		}
+	}
+
+	for _, a := range [][]string{{"zauth"}, {"zauth", "code", "alice"}} {
+		os.Args = a
+		err = ParseArgs(zc)
+		if err != nil {
+			t.Fatal(a, err)
+		}
+	}
+}
+
+func TestParseTagArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	os.Args = []string{"zauth", "import", "-type=andotp", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "entry", "-add-tag", "work, shared", "*org*"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "entry", "-remove-tag", "shared", "another"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "entry", "-new", "-secret", "JBSWY3DPEHPK3PXP", "-issuer", "NewOrg", "-account", "c@example.com", "-tags", "home"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(lst[0].Tags, lst[1].Tags, lst[2].Tags) != "[work shared] [work] [home]" {
+		t.Fatal("unexpected tags: ", lst[0].Tags, lst[1].Tags, lst[2].Tags)
+	}
+
+	for _, a := range [][]string{
+		{"zauth", "-tag", "shared"},
+		{"zauth", "-tag", "WORK", "another"},
+		{"zauth", "-tag", "home", "entry", "-list"},
+	} {
+		os.Args = a
+		err = ParseArgs(zc)
+		if err != nil {
+			t.Fatal(a, err)
+		}
+	}
+
+	os.Args = []string{"zauth", "-tag", "home", "another"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when no entry has tag")
+	}
+
+	os.Args = []string{"zauth", "-tag", "xyz", "entry", "-list"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when no entry has tag")
+	}
+}
+
+func TestParseEntryDetailArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	os.Args = []string{"zauth", "import", "-type=andotp", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// details are edited without prompts
+	os.Args = []string{"zauth", "entry", "-edit", "some org", "-notes", "Recovery codes in safe", "-url", "https://example.com/login", "-meta", "owner=alice", "-meta", "team=ops"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "entry", "-edit", "-meta", "team=", "some org"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "entry", "-new", "-secret", "JBSWY3DPEHPK3PXP", "-issuer", "NewOrg", "-account", "c@example.com", "-notes", "shared account"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := lst[0]
+	if z.Notes != "Recovery codes in safe" || z.URL != "https://example.com/login" || fmt.Sprint(z.Meta) != "map[owner:alice]" || z.Secret == "test" {
+		t.Fatalf("unexpected entry: %+v", z)
+	}
+	if lst[2].Notes != "shared account" {
+		t.Fatalf("unexpected entry: %+v", lst[2])
+	}
+
+	for _, a := range [][]string{
+		{"zauth", "entry", "-show", "some org"},
+		{"zauth", "-output", "json", "entry", "-show", "some org"},
+		{"zauth", "-output", "csv", "-secrets", "entry", "-show", "neworg"},
+	} {
+		os.Args = a
+		err = ParseArgs(zc)
+		if err != nil {
+			t.Fatal(a, err)
+		}
+	}
+
+	os.Args = []string{"zauth", "entry", "-edit", "some org", "-url", "example.com"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when url is invalid")
+	}
+
+	cols, row := entryRecord(&z, false)
+	b := &bytes.Buffer{}
+	err = writeRecord(b, OutputCsv, cols, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), ",https://example.com/login,Recovery codes in safe,owner=alice,") || strings.Contains(b.String(), z.Secret) {
+		t.Fatalf("unexpected output: %s", b.String())
+	}
+}
+
+func TestParseOutputArgs(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	os.Args = []string{"zauth", "import", "-type=andotp", fmt.Sprintf("-file=%s", test.TestAndotpAccountsJson)}
+	err := ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range SupportedOutputFormats {
+		for _, a := range [][]string{{}, {"entry", "-list"}, {"code", "1"}} {
+			os.Args = append([]string{"zauth", "-output", o}, a...)
+			err = ParseArgs(zc)
+			if err != nil {
+				t.Fatal(o, a, err)
+			}
+		}
+	}
+
+	os.Args = []string{"zauth", "-output", "json", "-secrets", "entry", "-list"}
+	err = ParseArgs(zc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// secrets are never shown in tables
+	os.Args = []string{"zauth", "-secrets"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when secrets are requested in table output")
+	}
+
+	os.Args = []string{"zauth", "-output", "xml"}
+	err = ParseArgs(zc)
+	if err == nil {
+		t.Fatal("expected test to fail when output format is invalid")
+	}
+
+	// entries whose code cannot be generated are listed with an error, and command fails
+	st := common.NewStore(test.TestZAuthJsonDir, zc)
+	err = st.WriteZAuthJson([]zauth.ZAuth{{Secret: "invalid!", Issuer: "Broken", Label: "Broken:x", Type: "totp", Digits: 6, Algorithm: "sha1", Period: 30}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := st.ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"zauth", "-output", "json"}
+	out, err := captureStdout(t, func() error { return ParseArgs(zc) })
+	if err == nil {
+		t.Fatal("expected test to fail when a code cannot be generated")
+	}
+
+	var recs []map[string]interface{}
+	err = json.Unmarshal([]byte(out), &recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != len(lst) {
+		t.Fatalf("expected records count: %d. received: %d", len(lst), len(recs))
+	}
+	if recs[len(recs)-1]["error"] == nil || recs[0]["error"] != nil {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+// captureStdout returns what f writes to stdout, and the error returned by f.
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- b
+	}()
+
+	ferr := f()
+	w.Close()
+	return string(<-done), ferr
+}
+
+func TestWriteEntries(t *testing.T) {
+	z := []zauth.ZAuth{
+		{ID: "7f0c9a4e-1b2d-4c3e-8f5a-6b7c8d9e0f1a", Secret: "JBSWY3DPEHPK3PXP", Issuer: "GitHub", Label: "GitHub:a,b", Digits: 6, Algorithm: "sha1", Period: 30, Type: "totp", Tags: []string{"work", "home"}},
+	}
+
+	tests := []struct {
+		o   string
+		sec bool
+		exp string
+	}{
+		{OutputJson, false, "[\n  {\n    \"index\": 1,\n    \"id\": \"7f0c9a4e-1b2d-4c3e-8f5a-6b7c8d9e0f1a\",\n    \"issuer\": \"GitHub\",\n    \"identifier\": \"a,b\",\n    \"label\": \"GitHub:a,b\",\n    \"type\": \"totp\",\n    \"tags\": [\n      \"work\",\n      \"home\"\n    ],\n    \"digits\": 6,\n    \"algorithm\": \"sha1\",\n    \"period\": 30,\n    \"counter\": 0\n  }\n]\n"},
+		{OutputCsv, false, "index,id,issuer,identifier,label,type,tags,digits,algorithm,period,counter\n1,7f0c9a4e-1b2d-4c3e-8f5a-6b7c8d9e0f1a,GitHub,\"a,b\",\"GitHub:a,b\",totp,\"work,home\",6,sha1,30,0\n"},
+		{OutputTsv, true, "index\tid\tissuer\tidentifier\tlabel\ttype\ttags\tdigits\talgorithm\tperiod\tcounter\tsecret\n1\t7f0c9a4e-1b2d-4c3e-8f5a-6b7c8d9e0f1a\tGitHub\ta,b\tGitHub:a,b\ttotp\twork,home\t6\tsha1\t30\t0\tJBSWY3DPEHPK3PXP\n"},
+	}
+
+	for _, tc := range tests {
+		b := &bytes.Buffer{}
+		err := writeEntries(b, tc.o, z, nil, tc.sec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tc.exp {
+			t.Fatalf("expected %q. received: %q", tc.exp, b.String())
+		}
+	}
+
+	// secrets are only written when requested
+	b := &bytes.Buffer{}
+	cols, row := otpRecord(&z[0], nil, nil, false)
+	err := writeRecord(b, OutputJson, cols, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), z[0].Secret) || !strings.Contains(b.String(), `"otp": null`) {
+		t.Fatalf("unexpected output: %s", b.String())
+	}
+}
+
+func assertEntryCount(t *testing.T, n int) {
+	lst, err := common.NewStore(test.TestZAuthJsonDir, zc).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lst) != n {
+		t.Fatalf("expected entries count: %d. received: %d", n, len(lst))
+	}
+}
+
+func (*zauthCommonTest) ReadPassword() (string, error) {
+	if isEmptyPassword {
+		return "", nil
+	}
+
+	if isErroredPassword {
+		return "", fmt.Errorf("password error")
+	}
+
+	return test.AndOtpAccountsEncPassword, nil
+}
+
+func resetPasswordFlags() {
+	isEmptyPassword = false
+	isErroredPassword = false
+}
+
+func (*zauthCommonTest) UserInput() (string, error) {
+	if onUserInput != nil {
+		onUserInput()
+	}
+
+	if len(userInputs) > 0 {
+		in := userInputs[0]
+		userInputs = userInputs[1:]
+		return in, nil
+	}
+	if isInputEOF {
+		return "", io.EOF
+	}
 	return "test", nil
 }
@@ -0,0 +1,281 @@
+package args
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// entryFlags holds entry fields supplied as entry -new flags.
+type entryFlags struct {
+	secret    *string
+	issuer    *string
+	account   *string
+	typ       *string
+	digits    *int
+	algorithm *string
+	period    *int64
+	counter   *int64
+	tags      *string
+	notes     *string
+	url       *string
+	meta      metaFlag
+}
+
+// Entry field flags, in usage order. Fields are only set by entry -new; notes, URL and metadata by entry -edit as well.
+var (
+	entryFieldFlags  = []string{"secret", "issuer", "account", "type", "digits", "algorithm", "period", "counter", "tags"}
+	entryDetailFlags = []string{"notes", "url", "meta"}
+)
+
+// unsupported returns entry field flags parsed by fs (as -name) which are not in allowed,
+// so that operations which do not use them can reject them instead of silently ignoring them.
+func (f *entryFlags) unsupported(fs *flag.FlagSet, allowed ...string) []string {
+	ok := make(map[string]bool)
+	for _, a := range allowed {
+		ok[a] = true
+	}
+
+	fl := make(map[string]bool)
+	for _, n := range append(entryFieldFlags, entryDetailFlags...) {
+		fl[n] = true
+	}
+
+	bad := make([]string, 0)
+	fs.Visit(func(v *flag.Flag) {
+		if fl[v.Name] && !ok[v.Name] {
+			bad = append(bad, "-"+v.Name)
+		}
+	})
+	return bad
+}
+
+// newEntryFlags defines entry field flags on f.
+func newEntryFlags(f *flag.FlagSet) *entryFlags {
+	meta := metaFlag{}
+	f.Var(meta, "meta", "Custom metadata of entry as key=value (eg: owner=alice). Can be repeated. Empty value removes key (optional)")
+
+	return &entryFlags{
+		meta:      meta,
+		secret:    f.String("secret", "", "Secret (base32) of new entry. Prompts are skipped for supplied fields (optional)"),
+		issuer:    f.String("issuer", "", "Issuer of new entry (eg: GitHub/Google..) (optional)"),
+		account:   f.String("account", "", "Identifier of new entry (eg: Username/email) (optional)"),
+		typ:       f.String("type", "", "Type of new entry (totp/hotp/steam) (optional)"),
+		digits:    f.Int("digits", 0, "Digits of new entry (optional)"),
+		algorithm: f.String("algorithm", "", "Algorithm of new entry (sha1/sha256/sha512) (optional)"),
+		period:    f.Int64("period", 0, "Period of new TOTP entry (optional)"),
+		counter:   f.Int64("counter", 0, "Counter of new HOTP entry (optional)"),
+		tags:      f.String("tags", "", "Comma separated tags of new entries (eg: work,personal) (optional)"),
+		notes:     f.String("notes", "", "Notes of entry (eg: where recovery codes are kept). Entry is edited without prompts if -notes, -url or -meta is supplied (optional)"),
+		url:       f.String("url", "", "Login URL of entry (optional)"),
+	}
+}
+
+// applyDetails sets notes, URL and metadata of entry z supplied as flags parsed by fs.
+// Reports whether any of them was supplied.
+func (f *entryFlags) applyDetails(z *zauth.ZAuth, fs *flag.FlagSet) (bool, error) {
+	set := false
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "notes":
+			z.Notes = strings.TrimSpace(*f.notes)
+		case "url":
+			z.URL = strings.TrimSpace(*f.url)
+			if e := validateURL(z.URL); e != nil {
+				err = e
+			}
+		case "meta":
+			for k, v := range f.meta {
+				if v == "" {
+					delete(z.Meta, k)
+					continue
+				}
+
+				if z.Meta == nil {
+					z.Meta = make(map[string]string)
+				}
+				z.Meta[k] = v
+			}
+		default:
+			return
+		}
+		set = true
+	})
+
+	return set, err
+}
+
+// metaFlag is a repeatable key=value flag.
+type metaFlag map[string]string
+
+func (m metaFlag) String() string {
+	return formatMeta(m)
+}
+
+func (m metaFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return fmt.Errorf("metadata must be key=value")
+	}
+
+	k := strings.TrimSpace(s[:i])
+	if k == "" {
+		return fmt.Errorf("metadata key cannot be empty")
+	}
+
+	m[k] = strings.TrimSpace(s[i+1:])
+	return nil
+}
+
+// formatMeta returns metadata m as comma separated key=value pairs, sorted by key.
+func formatMeta(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kv := make([]string, len(keys))
+	for i, k := range keys {
+		kv[i] = k + "=" + m[k]
+	}
+	return strings.Join(kv, ",")
+}
+
+// flagEntryInput is a ZAuthArgsEntryInput returning entry fields supplied as flags, so that entries can be created from scripts.
+// Supplied fields are validated and never prompted again. Missing required fields are read with in (see missing).
+// Missing optional fields take their default value.
+type flagEntryInput struct {
+	in  ZAuthArgsEntryInput
+	f   *entryFlags
+	set map[string]bool
+}
+
+// newFlagEntryInput returns flagEntryInput for flags of f parsed by fs.
+// Returns nil if no entry field flag was supplied.
+func newFlagEntryInput(in ZAuthArgsEntryInput, f *entryFlags, fs *flag.FlagSet) *flagEntryInput {
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "secret", "issuer", "account", "type", "digits", "algorithm", "period", "counter":
+			set[fl.Name] = true
+		}
+	})
+
+	if len(set) == 0 {
+		return nil
+	}
+
+	return &flagEntryInput{in: in, f: f, set: set}
+}
+
+// missing returns required fields (as -name flags) which were not supplied, and are read with in.
+func (fi *flagEntryInput) missing() []string {
+	m := make([]string, 0)
+	for _, n := range []string{"secret", "issuer", "account"} {
+		if !fi.set[n] {
+			m = append(m, "-"+n)
+		}
+	}
+	return m
+}
+
+// inapplicable returns supplied fields (as -name flags) which are not used by entries of supplied type (default: totp),
+// eg: -counter with totp. They are rejected instead of being silently dropped.
+func (fi *flagEntryInput) inapplicable() []string {
+	t := zauth.DefaultType
+	if fi.set["type"] {
+		t = strings.ToLower(strings.TrimSpace(*fi.f.typ))
+	}
+
+	unused := map[string][]string{
+		"totp":  {"counter"},
+		"hotp":  {"algorithm", "period"},
+		"steam": {"digits", "algorithm", "period", "counter"},
+	}
+
+	bad := make([]string, 0)
+	for _, n := range unused[t] {
+		if fi.set[n] {
+			bad = append(bad, "-"+n)
+		}
+	}
+	return bad
+}
+
+func (fi *flagEntryInput) ReadSecret(zc common.ZAuthCommonComp, def string) (string, error) {
+	if !fi.set["secret"] {
+		return fi.in.ReadSecret(zc, def)
+	}
+
+	sec := strings.ReplaceAll(strings.TrimSpace(*fi.f.secret), " ", "")
+	return sec, validateSecret(sec)
+}
+
+func (fi *flagEntryInput) ReadIssuer(zc common.ZAuthCommonComp, def string) (string, error) {
+	if !fi.set["issuer"] {
+		return fi.in.ReadIssuer(zc, def)
+	}
+
+	iss := strings.TrimSpace(*fi.f.issuer)
+	if iss == "" {
+		return "", fmt.Errorf("issuer cannot be empty")
+	}
+	return iss, nil
+}
+
+func (fi *flagEntryInput) ReadIdentifier(zc common.ZAuthCommonComp, def string) (string, error) {
+	if !fi.set["account"] {
+		return fi.in.ReadIdentifier(zc, def)
+	}
+
+	acc := strings.TrimSpace(*fi.f.account)
+	if acc == "" {
+		return "", fmt.Errorf("identifier cannot be empty")
+	}
+	return acc, nil
+}
+
+func (fi *flagEntryInput) ReadType(zc common.ZAuthCommonComp, def string) (string, error) {
+	if !fi.set["type"] {
+		return strings.ToLower(def), nil
+	}
+
+	t := strings.ToLower(strings.TrimSpace(*fi.f.typ))
+	return t, validateType(t)
+}
+
+func (fi *flagEntryInput) ReadDigits(zc common.ZAuthCommonComp, def int) (int, error) {
+	if !fi.set["digits"] {
+		return def, nil
+	}
+	return *fi.f.digits, validateDigits(*fi.f.digits)
+}
+
+func (fi *flagEntryInput) ReadAlgorithm(zc common.ZAuthCommonComp, def string) (string, error) {
+	if !fi.set["algorithm"] {
+		return strings.ToLower(def), nil
+	}
+
+	a := strings.ToLower(strings.TrimSpace(*fi.f.algorithm))
+	return a, validateAlgorithm(a)
+}
+
+func (fi *flagEntryInput) ReadPeriod(zc common.ZAuthCommonComp, def int64) (int64, error) {
+	if !fi.set["period"] {
+		return def, nil
+	}
+	return *fi.f.period, validatePeriod(*fi.f.period)
+}
+
+func (fi *flagEntryInput) ReadCounter(zc common.ZAuthCommonComp, def int64) (int64, error) {
+	if !fi.set["counter"] {
+		return def, nil
+	}
+	return *fi.f.counter, validateCounter(*fi.f.counter)
+}
@@ -0,0 +1,143 @@
+package args
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output formats supported by --output.
+const (
+	OutputTable = "table"
+	OutputJson  = "json"
+	OutputCsv   = "csv"
+	OutputTsv   = "tsv"
+)
+
+var SupportedOutputFormats = []string{OutputTable, OutputJson, OutputCsv, OutputTsv}
+
+// validateOutput returns an error if o is not a supported output format.
+// Secrets (sec) are only written in machine-readable formats, so that they are never shown by mistake in a table.
+func validateOutput(o string, sec bool) error {
+	for _, f := range SupportedOutputFormats {
+		if o == f {
+			if sec && o == OutputTable {
+				return fmt.Errorf("-secrets requires -output %s", strings.Join(SupportedOutputFormats[1:], ", "))
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output format: %s (supported: %s)", o, strings.Join(SupportedOutputFormats, ", "))
+}
+
+// writeRecords writes rows in machine-readable format o (json, csv or tsv) to w.
+// Each row has a value for each column of cols. nil values are written as null (json) or empty fields (csv, tsv).
+// String arrays (eg: tags) and maps (eg: metadata) are written as JSON arrays and objects, or comma separated fields.
+// JSON output is an array of objects keyed by column, in column order.
+func writeRecords(w io.Writer, o string, cols []string, rows [][]interface{}) error {
+	if o == OutputJson {
+		objs := make([]json.RawMessage, len(rows))
+		for i, r := range rows {
+			b, err := jsonObject(cols, r)
+			if err != nil {
+				return err
+			}
+			objs[i] = b
+		}
+
+		b, err := json.MarshalIndent(objs, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	return writeDelimited(w, o, cols, rows)
+}
+
+// writeRecord writes a single row in machine-readable format o to w. JSON output is a single object.
+func writeRecord(w io.Writer, o string, cols []string, row []interface{}) error {
+	if o == OutputJson {
+		b, err := jsonObject(cols, row)
+		if err != nil {
+			return err
+		}
+
+		var out bytes.Buffer
+		err = json.Indent(&out, b, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out.Bytes())
+		return err
+	}
+
+	return writeDelimited(w, o, cols, [][]interface{}{row})
+}
+
+// writeDelimited writes header cols followed by rows in csv or tsv format o to w.
+func writeDelimited(w io.Writer, o string, cols []string, rows [][]interface{}) error {
+	cw := csv.NewWriter(w)
+	if o == OutputTsv {
+		cw.Comma = '\t'
+	}
+
+	err := cw.Write(cols)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		rec := make([]string, len(r))
+		for i, v := range r {
+			switch v := v.(type) {
+			case nil:
+			case []string:
+				rec[i] = strings.Join(v, ",")
+			case map[string]string:
+				rec[i] = formatMeta(v)
+			default:
+				rec[i] = fmt.Sprint(v)
+			}
+		}
+
+		err = cw.Write(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonObject returns JSON object with keys cols and values row. Keys are kept in column order.
+func jsonObject(cols []string, row []interface{}) (json.RawMessage, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, c := range cols {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		k, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(row[i])
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
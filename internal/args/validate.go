@@ -0,0 +1,109 @@
+package args
+
+import (
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+func validateSecret(sec string) error {
+	if sec == "" {
+		return fmt.Errorf("secret cannot be empty")
+	}
+
+	_, err := base32.StdEncoding.DecodeString(sec)
+	if err != nil {
+		return fmt.Errorf("invalid base32 secret")
+	}
+
+	return nil
+}
+
+func validateType(t string) error {
+	t = strings.ToLower(t)
+	if t != "totp" && t != "hotp" && t != "steam" {
+		return fmt.Errorf("invalid type: %s", t)
+	}
+	return nil
+}
+
+func validateDigits(d int) error {
+	if d < 1 || d > 10 {
+		return fmt.Errorf("invalid digits: %d", d)
+	}
+	return nil
+}
+
+func validateAlgorithm(a string) error {
+	a = strings.ToLower(a)
+	if a != "sha1" && a != "sha256" && a != "sha512" {
+		return fmt.Errorf("invalid algorithm: %s", a)
+	}
+	return nil
+}
+
+func validateURL(u string) error {
+	if u == "" {
+		return nil
+	}
+
+	p, err := url.Parse(u)
+	if err != nil || p.Host == "" || (p.Scheme != "http" && p.Scheme != "https") {
+		return fmt.Errorf("invalid url: %s", u)
+	}
+	return nil
+}
+
+func validatePeriod(p int64) error {
+	if p <= 0 {
+		return fmt.Errorf("invalid period: %d", p)
+	}
+	return nil
+}
+
+func validateCounter(c int64) error {
+	if c < 0 {
+		return fmt.Errorf("invalid counter: %d", c)
+	}
+	return nil
+}
+
+// validateZAuth validates all fields of entry z.
+func validateZAuth(z *zauth.ZAuth) error {
+	err := validateSecret(z.Secret)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(z.Issuer) == "" {
+		return fmt.Errorf("issuer cannot be empty")
+	}
+
+	if strings.TrimSpace(z.Label) == "" {
+		return fmt.Errorf("label cannot be empty")
+	}
+
+	err = validateType(z.Type)
+	if err != nil {
+		return err
+	}
+
+	err = validateDigits(z.Digits)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(z.Type) != "hotp" {
+		err = validateAlgorithm(z.Algorithm)
+		if err != nil {
+			return err
+		}
+
+		return validatePeriod(z.Period)
+	}
+
+	return validateCounter(z.Counter)
+}
@@ -0,0 +1,174 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Clipboard is a system clipboard.
+type Clipboard interface {
+	// Copy places text s on clipboard.
+	Copy(s string) error
+
+	// Clear removes text placed by Copy from clipboard. Clipboards which can be read are only cleared if they still
+	// hold that text, so that text copied meanwhile (eg: by user) is kept. Other clipboards are cleared regardless.
+	Clear() error
+
+	// Name returns name of clipboard mechanism (eg: wl-copy).
+	Name() string
+}
+
+// Detect returns clipboard available in current environment. It is selected in following order:
+//   - wl-copy (wayland)
+//   - xclip or xsel (X11)
+//   - pbcopy (macOS)
+//   - OSC52 terminal escape sequence, written to terminal (works over ssh on most terminal emulators)
+func Detect() Clipboard {
+	if os.Getenv("WAYLAND_DISPLAY") != "" && hasCommand("wl-copy") {
+		return &commandClipboard{
+			name:  "wl-copy",
+			copy:  []string{"wl-copy"},
+			paste: []string{"wl-paste", "--no-newline"},
+			clear: []string{"wl-copy", "--clear"},
+		}
+	}
+
+	if os.Getenv("DISPLAY") != "" {
+		if hasCommand("xclip") {
+			return &commandClipboard{
+				name:  "xclip",
+				copy:  []string{"xclip", "-selection", "clipboard"},
+				paste: []string{"xclip", "-selection", "clipboard", "-out"},
+			}
+		}
+
+		if hasCommand("xsel") {
+			return &commandClipboard{
+				name:  "xsel",
+				copy:  []string{"xsel", "--clipboard", "--input"},
+				paste: []string{"xsel", "--clipboard", "--output"},
+				clear: []string{"xsel", "--clipboard", "--delete"},
+			}
+		}
+	}
+
+	if runtime.GOOS == "darwin" && hasCommand("pbcopy") {
+		return &commandClipboard{
+			name:  "pbcopy",
+			copy:  []string{"pbcopy"},
+			paste: []string{"pbpaste"},
+		}
+	}
+
+	return NewOSC52(ttyWriter{}, os.Getenv("TMUX") != "")
+}
+
+// ttyWriter writes to controlling terminal, so that OSC52 reaches the terminal even if stdout is redirected
+// (eg: otp=$(zauth code github --copy)). Terminal is opened (and closed) on each write, so that no file is left open.
+// If there is no controlling terminal, stderr is written instead.
+type ttyWriter struct{}
+
+func (ttyWriter) Write(p []byte) (int, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return os.Stderr.Write(p)
+	}
+	defer tty.Close()
+
+	return tty.Write(p)
+}
+
+func hasCommand(c string) bool {
+	_, err := exec.LookPath(c)
+	return err == nil
+}
+
+// commandClipboard is a clipboard managed by external commands. Text is written to stdin of copy command.
+// If clear command is not set, clipboard is cleared by copying empty text. If paste command is set, it is used
+// to check that clipboard still holds copied text before clearing it.
+type commandClipboard struct {
+	name   string
+	copy   []string
+	paste  []string
+	clear  []string
+	copied string // text placed by last Copy
+}
+
+func (c *commandClipboard) Copy(s string) error {
+	cmd := exec.Command(c.copy[0], c.copy[1:]...)
+	cmd.Stdin = strings.NewReader(s)
+
+	// stdout and stderr are not captured: xclip and wl-copy fork a process which keeps them open to serve the clipboard,
+	// so that waiting for them to be closed would block until clipboard is replaced
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.name, err)
+	}
+
+	c.copied = s
+	return nil
+}
+
+func (c *commandClipboard) Clear() error {
+	if c.paste != nil {
+		out, err := exec.Command(c.paste[0], c.paste[1:]...).Output()
+		if err == nil && string(out) != c.copied {
+			return nil
+		}
+	}
+
+	if c.clear == nil {
+		return c.Copy("")
+	}
+
+	err := exec.Command(c.clear[0], c.clear[1:]...).Run()
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.name, err)
+	}
+	return nil
+}
+
+func (c *commandClipboard) Name() string {
+	return c.name
+}
+
+// OSC52 is a clipboard set with OSC52 terminal escape sequence.
+// Sequence is interpreted by terminal emulator, so no display server is required.
+type OSC52 struct {
+	w    io.Writer
+	tmux bool
+}
+
+// NewOSC52 returns OSC52 clipboard writing escape sequences to w (usually terminal).
+// If tmux is true, sequences are wrapped to be passed through by tmux to outer terminal.
+func NewOSC52(w io.Writer, tmux bool) *OSC52 {
+	return &OSC52{w: w, tmux: tmux}
+}
+
+func (o *OSC52) Copy(s string) error {
+	return o.write(base64.StdEncoding.EncodeToString([]byte(s)))
+}
+
+// Clear sets clipboard to invalid base64 data, which clears clipboard.
+func (o *OSC52) Clear() error {
+	return o.write("!")
+}
+
+func (o *OSC52) Name() string {
+	return "OSC52"
+}
+
+func (o *OSC52) write(d string) error {
+	seq := "\x1b]52;c;" + d + "\a"
+	if o.tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+
+	_, err := io.WriteString(o.w, seq)
+	return err
+}
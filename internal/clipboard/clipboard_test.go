@@ -0,0 +1,96 @@
+package clipboard
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSC52(t *testing.T) {
+	b := &bytes.Buffer{}
+	o := NewOSC52(b, false)
+
+	err := o.Copy("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "\x1b]52;c;MTIzNDU2\a" {
+		t.Fatalf("unexpected copy sequence: %q", b.String())
+	}
+
+	b.Reset()
+	err = o.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "\x1b]52;c;!\a" {
+		t.Fatalf("unexpected clear sequence: %q", b.String())
+	}
+}
+
+func TestOSC52Tmux(t *testing.T) {
+	b := &bytes.Buffer{}
+	o := NewOSC52(b, true)
+
+	err := o.Copy("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "\x1bPtmux;\x1b\x1b]52;c;MTIzNDU2\a\x1b\\" {
+		t.Fatalf("unexpected copy sequence: %q", b.String())
+	}
+}
+
+func TestCommandClipboard(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	f := filepath.Join(t.TempDir(), "clipboard")
+	c := &commandClipboard{
+		name:  "test",
+		copy:  []string{"sh", "-c", "cat > " + f},
+		paste: []string{"cat", f},
+	}
+
+	err := c.Copy("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, f, "123456")
+
+	err = c.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, f, "")
+
+	// text copied meanwhile is kept
+	err = c.Copy("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(f, []byte("copied by user"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, f, "copied by user")
+}
+
+func assertFile(t *testing.T, f string, exp string) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != exp {
+		t.Fatalf("expected clipboard: %q. received: %q", exp, string(b))
+	}
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// BackupSuffix is appended to file name of the previous version of a file kept by writeFileBackup.
+const BackupSuffix = ".bak"
+
+// writeFileAtomic writes data d to file f with permissions perm.
+// d is written to a temporary file in the same directory, which is synced to disk and renamed over f.
+// Thus f is either left unchanged or fully written, even if writing is interrupted.
+func writeFileAtomic(f string, d []byte, perm os.FileMode) error {
+	dir := filepath.Dir(f)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// temporary file is removed if anything goes wrong. After rename, Remove is a no-op.
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(d)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), f)
+	if err != nil {
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// writeFileBackup atomically writes data d to file f, keeping previous content of f as f.bak.
+// Previous backup is replaced.
+func writeFileBackup(f string, d []byte, perm os.FileMode) error {
+	old, err := os.ReadFile(f)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err == nil {
+		err = writeFileAtomic(f+BackupSuffix, old, perm)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(f, d, perm)
+}
+
+// syncDir syncs directory dir, so that renames in dir are persisted.
+// Errors are ignored, since directories cannot be synced on all platforms (eg: windows).
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -2,25 +2,36 @@ package common
 
 import (
 	"bufio"
-	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"syscall"
 	"time"
 
-	"github.com/grijul/zauth/internal/zauth"
 	"golang.org/x/term"
 )
 
-type ZAuthCommon struct{}
+// PasswordEnv is the environment variable holding vault password, for non-interactive use (eg: scripts).
+const PasswordEnv = "ZAUTH_PASSWORD"
+
+type ZAuthCommon struct {
+	rd *bufio.Reader
+}
 
 type PasswordReader interface {
 	ReadPassword() (string, error)
 }
 
+// StaticPassword is a PasswordReader returning a password known in advance (see PasswordEnv).
+// Store does not prompt for vault password when it's PasswordReader is a StaticPassword.
+type StaticPassword string
+
+func (p StaticPassword) ReadPassword() (string, error) {
+	return string(p), nil
+}
+
 type UserInputReader interface {
 	UserInput() (string, error)
 }
@@ -48,72 +59,36 @@ func GetFileName(t string, enc bool) string {
 	return name
 }
 
-// WriteFile writes d bytes to file f.
+// WriteFile writes d bytes to file f in directory dir.
 // If file's directory does it exist, it is created.
 // If file exists, it is overwritten.
 // Returns write location and any errors occured
-func WriteFile(d []byte, f string) (string, error) {
-	err := os.MkdirAll(zauth.ZAuthJsonDir, os.ModePerm)
+func WriteFile(d []byte, dir string, f string) (string, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
 		return "", err
 	}
 
-	fl := filepath.Join(zauth.ZAuthJsonDir, f)
-	return fl, os.WriteFile(fl, d, 0644)
+	fl := filepath.Join(dir, f)
+	return fl, writeFileAtomic(fl, d, 0644)
 }
 
-// WriteZAuthJson writes ZAuth array objects as json to zauth.json file.
-// File is overwritten with new content if ow is true. Else entries in z are appended to existing file.
-func WriteZAuthJson(z []zauth.ZAuth, ow bool) error {
-	err := os.MkdirAll(zauth.ZAuthJsonDir, os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	if ow {
-		b, err := json.Marshal(z)
-		if err != nil {
-			return err
-		}
-
-		return os.WriteFile(zauth.ZAuthJson, b, 0644)
-	} else {
-
-		zj, err := ReadZAuthJson()
-		if err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				return err
-			}
-		}
-
-		zj = append(zj, z...)
-		b, err := json.Marshal(zj)
+// ReadPassword reads a password from terminal without echo.
+// If stdin is not a terminal (eg: URIs piped to zauth), password is read from controlling terminal instead.
+func (zc *ZAuthCommon) ReadPassword() (string, error) {
+	fd := int(syscall.Stdin)
+	if !term.IsTerminal(fd) {
+		tty, err := openTTY()
 		if err != nil {
-			return err
+			msg := fmt.Sprintf("error reading password: no terminal available (set %s): %v", PasswordEnv, err)
+			fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
+			return "", fmt.Errorf(msg)
 		}
-
-		return os.WriteFile(zauth.ZAuthJson, b, 0644)
-	}
-}
-
-// ReadZAuthJson reads zauth.json file and returns it's equivalent ZAuth array object
-func ReadZAuthJson() ([]zauth.ZAuth, error) {
-	b, err := os.ReadFile(zauth.ZAuthJson)
-	if err != nil {
-		return nil, err
+		defer tty.Close()
+		fd = int(tty.Fd())
 	}
 
-	z := make([]zauth.ZAuth, 0)
-	err = json.Unmarshal(b, &z)
-	if err != nil {
-		return nil, err
-	}
-
-	return z, nil
-}
-
-func (zc *ZAuthCommon) ReadPassword() (string, error) {
-	pass, err := term.ReadPassword(int(syscall.Stdin))
+	pass, err := term.ReadPassword(fd)
 	if err != nil {
 		msg := fmt.Sprintf("error reading password: %v", err)
 		fmt.Fprintf(flag.CommandLine.Output(), "%s\n", msg)
@@ -122,7 +97,15 @@ func (zc *ZAuthCommon) ReadPassword() (string, error) {
 	return string(pass), nil
 }
 
+// UserInput reads a line from stdin. Returns io.EOF once stdin is exhausted.
 func (zc *ZAuthCommon) UserInput() (string, error) {
-	rd := bufio.NewReader(os.Stdin)
-	return rd.ReadString('\n')
+	if zc.rd == nil {
+		zc.rd = bufio.NewReader(os.Stdin)
+	}
+
+	l, err := zc.rd.ReadString('\n')
+	if err == io.EOF && l != "" {
+		return l, nil
+	}
+	return l, err
 }
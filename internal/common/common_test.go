@@ -1,7 +1,11 @@
 package common
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -9,9 +13,10 @@ import (
 	"github.com/grijul/zauth/test"
 )
 
-func init() {
-	zauth.ZAuthJson = test.TestZAuthJson
-	zauth.ZAuthJsonDir = test.TestZAuthJsonDir
+type wrongPasswordReader struct{}
+
+func (*wrongPasswordReader) ReadPassword() (string, error) {
+	return "wrongpass", nil
 }
 
 func TestGetFileName(t *testing.T) {
@@ -28,14 +33,54 @@ func TestGetFileName(t *testing.T) {
 	}
 }
 
+func TestDataDir(t *testing.T) {
+	env, ok := os.LookupEnv(DirEnv)
+	defer func() {
+		if ok {
+			os.Setenv(DirEnv, env)
+		} else {
+			os.Unsetenv(DirEnv)
+		}
+	}()
+
+	os.Setenv(DirEnv, test.TestZAuthJsonDir)
+
+	// flag takes precedence over environment
+	d, err := DataDir(filepath.Join(test.TestZAuthJsonDir, "flag"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != filepath.Join(test.TestZAuthJsonDir, "flag") {
+		t.Fatal("unexpected dir: ", d)
+	}
+
+	d, err = DataDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != test.TestZAuthJsonDir {
+		t.Fatal("unexpected dir: ", d)
+	}
+
+	os.Unsetenv(DirEnv)
+	d, err = DataDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == "" || d == test.TestZAuthJsonDir {
+		t.Fatal("unexpected dir: ", d)
+	}
+}
+
 func TestWriteZAuthJson(t *testing.T) {
 	test.RemoveTestFiles()
+	st := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{})
 	z := zauth.ZAuth{
 		Secret: "test",
 		Label:  "test",
 	}
 
-	err := WriteZAuthJson([]zauth.ZAuth{z}, false)
+	err := st.WriteZAuthJson([]zauth.ZAuth{z}, false)
 	if err != nil {
 		t.Fatal(err)
 		test.RemoveTestFiles()
@@ -46,15 +91,23 @@ func TestWriteZAuthJson(t *testing.T) {
 		Label:  "test2",
 	}
 
-	err = WriteZAuthJson([]zauth.ZAuth{z2}, false)
+	err = st.WriteZAuthJson([]zauth.ZAuth{z2}, false)
 	if err != nil {
 		t.Fatal(err)
 		test.RemoveTestFiles()
 	}
+
+	b, err := os.ReadFile(test.TestZAuthJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsVault(b) {
+		t.Fatal("expected zauth.json to be encrypted")
+	}
 }
 
 func TestReadZAuthJson(t *testing.T) {
-	z, err := ReadZAuthJson()
+	z, err := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}).ReadZAuthJson()
 	if err != nil {
 		t.Fatal(err)
 		test.RemoveTestFiles()
@@ -64,5 +117,569 @@ func TestReadZAuthJson(t *testing.T) {
 		test.RemoveTestFiles()
 	}
 
+	// wrong password
+	_, err = NewStore(test.TestZAuthJsonDir, &wrongPasswordReader{}).ReadZAuthJson()
+	if !errors.Is(err, ErrVaultPassword) {
+		t.Fatal("expected test to fail with wrong password. received: ", err)
+	}
+
+	test.RemoveTestFiles()
+}
+
+func TestReadZAuthJsonLegacy(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	b, _ := json.Marshal([]zauth.ZAuth{{Secret: "test", Label: "test"}})
+	os.MkdirAll(test.TestZAuthJsonDir, 0700)
+	err := os.WriteFile(test.TestZAuthJson, b, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plaintext file is encrypted in place on first read
+	z, err := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 1 || z[0].Secret != "test" {
+		t.Fatal("unexpected entries: ", z)
+	}
+
+	b, err = os.ReadFile(test.TestZAuthJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsVault(b) {
+		t.Fatal("expected plaintext zauth.json to be encrypted")
+	}
+
+	_, err = os.Stat(test.TestZAuthJson + BackupSuffix)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected no backup of plaintext zauth.json")
+	}
+
+	// IDs are backfilled and saved
+	id := z[0].ID
+	if id == "" {
+		t.Fatal("expected entry ID to be assigned")
+	}
+
+	z, err = NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 1 {
+		t.Fatal("expected entries count: 1. received: ", len(z))
+	}
+	if z[0].ID != id {
+		t.Fatalf("expected stable entry ID: %s. received: %s", id, z[0].ID)
+	}
+}
+
+func TestWriteBackup(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	st := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{})
+	for _, l := range []string{"first", "second", "third"} {
+		err := st.WriteZAuthJson([]zauth.ZAuth{{Secret: "test", Label: l}}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// backup holds previous version
+	bst := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{})
+	bst.Path = test.TestZAuthJson + BackupSuffix
+	z, err := bst.ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 2 {
+		t.Fatal("expected backup entries count: 2. received: ", len(z))
+	}
+
+	// usage tracking does not replace backup
+	v, err := st.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	RecordUsage(&v.Entries[0], time.Now())
+	err = st.WriteUsage(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err = bst.ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 2 {
+		t.Fatal("expected backup entries count: 2. received: ", len(z))
+	}
+
+	// no temporary files are left behind (zauth.json, backup and lock file)
+	fl, err := os.ReadDir(test.TestZAuthJsonDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fl) != 3 {
+		t.Fatal("unexpected files in zauth dir: ", fl)
+	}
+
+	// purge removes backup, as it holds purged entries
+	err = st.WritePurge(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(bst.Path)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected backup to be removed: ", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	tm := LockTimeout
+	LockTimeout = 200 * time.Millisecond
+	defer func() { LockTimeout = tm }()
+
+	s1 := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{})
+	s2 := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{})
+
+	err := s1.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lock is reentrant
+	err = s1.WriteZAuthJson([]zauth.ZAuth{{Secret: "test", Label: "test"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s2.WriteZAuthJson([]zauth.ZAuth{{Secret: "test2", Label: "test2"}}, false)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatal("expected test to fail when store is locked. received: ", err)
+	}
+
+	err = s1.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s2.WriteZAuthJson([]zauth.ZAuth{{Secret: "test2", Label: "test2"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lockCheckReader is a PasswordReader returning TestVaultPassword, which records whether store other was locked when
+// password was read.
+type lockCheckReader struct {
+	other  *Store
+	locked bool
+}
+
+func (r *lockCheckReader) ReadPassword() (string, error) {
+	err := r.other.Lock()
+	if errors.Is(err, ErrLocked) {
+		r.locked = true
+	} else if err == nil {
+		r.other.Unlock()
+	}
+	return test.TestVaultPassword, nil
+}
+
+func TestLockPrompt(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	tm := LockTimeout
+	LockTimeout = 100 * time.Millisecond
+	defer func() { LockTimeout = tm }()
+
+	// password is never read while store is locked: new vault, existing vault and legacy file
+	b, _ := json.Marshal([]zauth.ZAuth{{Secret: "test", Label: "test"}})
+	for _, legacy := range []bool{false, true} {
+		test.RemoveTestFiles()
+		if legacy {
+			os.MkdirAll(test.TestZAuthJsonDir, 0700)
+			err := os.WriteFile(test.TestZAuthJson, b, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := 0; i < 2; i++ {
+			pr := &lockCheckReader{other: NewStore(test.TestZAuthJsonDir, nil)}
+			err := NewStore(test.TestZAuthJsonDir, pr).WriteZAuthJson([]zauth.ZAuth{{Secret: "test", Label: "test"}}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pr.locked {
+				t.Fatalf("expected store to be unlocked while reading password (legacy: %v, write: %d)", legacy, i+1)
+			}
+		}
+	}
+
+	err := os.WriteFile(test.TestZAuthJson, b, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr := &lockCheckReader{other: NewStore(test.TestZAuthJsonDir, nil)}
+	_, err = NewStore(test.TestZAuthJsonDir, pr).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.locked {
+		t.Fatal("expected store to be unlocked while reading password of migrated file")
+	}
+}
+
+func TestVaults(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	pr := &test.VaultPasswordReader{}
+	err := NewStore(test.TestZAuthJsonDir, pr).WriteZAuthJson([]zauth.ZAuth{{Secret: "test", Label: "test"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []string{"work", "personal"} {
+		err = CreateVault(test.TestZAuthJsonDir, n, pr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = CreateVault(test.TestZAuthJsonDir, "work", pr)
+	if !errors.Is(err, ErrVaultExist) {
+		t.Fatal("expected test to fail when vault exists. received: ", err)
+	}
+
+	err = CreateVault(test.TestZAuthJsonDir, "../work", pr)
+	if err == nil {
+		t.Fatal("expected test to fail when vault name is invalid")
+	}
+
+	// entries are not shared between vaults
+	st, err := NewVaultStore(test.TestZAuthJsonDir, "work", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.WriteZAuthJson([]zauth.ZAuth{{Secret: "test", Label: "work1"}, {Secret: "test", Label: "work2"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := NewStore(test.TestZAuthJsonDir, pr).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 1 {
+		t.Fatal("expected entries count: 1. received: ", len(z))
+	}
+
+	err = RenameVault(test.TestZAuthJsonDir, "work", "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RemoveVault(test.TestZAuthJsonDir, "personal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RemoveVault(test.TestZAuthJsonDir, DefaultVault)
+	if err == nil {
+		t.Fatal("expected test to fail when removing default vault")
+	}
+
+	names, err := ListVaults(test.TestZAuthJsonDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[default team]" {
+		t.Fatal("unexpected vaults: ", names)
+	}
+
+	st, err = NewVaultStore(test.TestZAuthJsonDir, "team", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err = st.ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 2 {
+		t.Fatal("expected entries count: 2. received: ", len(z))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		d   string
+		ver int
+	}{
+		{`[{"secret":"test","label":"test"}]`, 0},
+		{`{"entries":[{"secret":"test","label":"test"}],"trash":[]}`, 1},
+		{`{"version":2,"meta":{"name":"default","created":1,"modified":2},"entries":[{"secret":"test","label":"test"}],"trash":[]}`, 2},
+	}
+
+	for _, tc := range tests {
+		v, ver, err := decodeVault([]byte(tc.d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ver != tc.ver {
+			t.Fatalf("expected version: %d. received: %d", tc.ver, ver)
+		}
+		if v.Version != SchemaVersion || len(v.Entries) != 1 || v.Trash == nil {
+			t.Fatal("unexpected vault after migration: ", v)
+		}
+	}
+
+	// tags and notes are moved from misc
+	v, _, err := decodeVault([]byte(`{"version":2,"entries":[{"label":"test","misc":{"tags":["work"],"groups":["Work"," home ",""],"note":"test note","thumbnail":"Default"}}],"trash":[{"label":"test"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(v.Entries[0].Tags) != "[work home]" || v.Entries[0].Notes != "test note" || len(v.Entries[0].Misc) != 1 || v.Trash[0].Tags == nil {
+		t.Fatal("unexpected entries after migration: ", v.Entries, v.Trash)
+	}
+
+	_, _, err = decodeVault([]byte(`{"version":"x"}`))
+	if err == nil {
+		t.Fatal("expected test to fail when version is invalid")
+	}
+}
+
+func TestNewerSchema(t *testing.T) {
+	defer test.RemoveTestFiles()
 	test.RemoveTestFiles()
+
+	st := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{})
+	err := st.WriteZAuthJson([]zauth.ZAuth{{Secret: "test", Label: "test"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := st.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != SchemaVersion || v.Meta.Name != DefaultVault || v.Meta.Created == 0 || v.Meta.Modified == 0 {
+		t.Fatal("unexpected vault metadata: ", v.Version, v.Meta)
+	}
+
+	// simulate file written by a newer version of zauth
+	b, err := json.Marshal(map[string]interface{}{
+		"version": SchemaVersion + 1,
+		"entries": v.Entries,
+		"trash":   v.Trash,
+		"unknown": "data",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = encryptVault(b, st.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(test.TestZAuthJson, b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// newer file can be read, but not written
+	z, err := NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 1 {
+		t.Fatal("expected entries count: 1. received: ", len(z))
+	}
+
+	err = NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}).WriteZAuthJson(z, true)
+	if !errors.Is(err, ErrNewerSchema) {
+		t.Fatal("expected test to fail when writing newer file. received: ", err)
+	}
+
+	// file changed since it was read is checked again
+	err = st.Write(v)
+	if !errors.Is(err, ErrNewerSchema) {
+		t.Fatal("expected test to fail when writing newer file. received: ", err)
+	}
+}
+
+func TestFilterEntries(t *testing.T) {
+	z := []zauth.ZAuth{
+		{Issuer: "GitHub", Label: "GitHub:alice"},
+		{Issuer: "Google", Label: "Google:alice@example.com"},
+		{Issuer: "Git", Label: "Git:bob", Tags: []string{"Work"}},
+	}
+
+	tests := []struct {
+		q   string
+		idx []int
+	}{
+		{"", []int{0, 1, 2}},
+		{"git", []int{0, 2}},
+		{"GTH", []int{0}},
+		{"alice", []int{0, 1}},
+		{"work", []int{2}},
+		{"xyz", []int{}},
+	}
+
+	for _, tc := range tests {
+		idx := FilterEntries(z, tc.q)
+		if fmt.Sprint(idx) != fmt.Sprint(tc.idx) {
+			t.Fatalf("query %q: expected %v. received: %v", tc.q, tc.idx, idx)
+		}
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	z := []zauth.ZAuth{
+		{Issuer: "google", Label: "google:bob"},
+		{Issuer: "GitHub", Label: "GitHub:bob", Misc: map[string]interface{}{MiscLastUsed: float64(2000), MiscUsedFrequency: float64(2)}},
+		{Issuer: "Google", Label: "Google:alice", Misc: map[string]interface{}{MiscLastUsed: float64(1000), MiscUsedFrequency: float64(5)}},
+	}
+	RecordUsage(&z[0], time.Unix(3, 0))
+
+	tests := []struct {
+		s   string
+		idx []int
+	}{
+		{SortNone, []int{0, 1, 2}},
+		{SortIssuer, []int{1, 2, 0}},
+		{SortLabel, []int{1, 2, 0}},
+		{SortRecent, []int{0, 1, 2}},
+		{SortFrequency, []int{2, 1, 0}},
+	}
+
+	for _, tc := range tests {
+		idx := []int{0, 1, 2}
+		err := SortEntries(z, idx, tc.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(idx) != fmt.Sprint(tc.idx) {
+			t.Fatalf("sort %q: expected %v. received: %v", tc.s, tc.idx, idx)
+		}
+	}
+
+	if LastUsed(&z[0]) != 3000 || UsedFrequency(&z[0]) != 1 {
+		t.Fatalf("unexpected usage: %v", z[0].Misc)
+	}
+
+	err := SortEntries(z, []int{0}, "xyz")
+	if err == nil {
+		t.Fatal("expected test to fail when sort order is invalid")
+	}
+}
+
+func TestTags(t *testing.T) {
+	if tags := ParseTags(" work, Personal,,WORK "); fmt.Sprint(tags) != "[work Personal]" {
+		t.Fatal("unexpected tags: ", tags)
+	}
+
+	z := []zauth.ZAuth{{Tags: []string{"work"}}, {}}
+	if !AddTags(&z[1], []string{"Home", "home"}) || AddTags(&z[1], []string{"HOME"}) || fmt.Sprint(z[1].Tags) != "[Home]" {
+		t.Fatal("unexpected tags: ", z[1].Tags)
+	}
+
+	if idx := FilterTag(z, []int{0, 1}, "HOME"); fmt.Sprint(idx) != "[1]" {
+		t.Fatal("unexpected filtered entries: ", idx)
+	}
+	if idx := FilterTag(z, []int{0, 1}, ""); fmt.Sprint(idx) != "[0 1]" {
+		t.Fatal("unexpected filtered entries: ", idx)
+	}
+
+	if !RemoveTags(&z[0], []string{"Work"}) || RemoveTags(&z[0], []string{"work"}) || len(z[0].Tags) != 0 {
+		t.Fatal("unexpected tags: ", z[0].Tags)
+	}
+}
+
+func TestVault(t *testing.T) {
+	k, err := newVaultKey("pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := encryptVault([]byte("test"), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt, _, err := decryptVault(d, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pt) != "test" {
+		t.Fatal("unexpected output: ", string(pt))
+	}
+
+	_, _, err = decryptVault(d, "wrong")
+	if !errors.Is(err, ErrVaultPassword) {
+		t.Fatal("expected decryption to fail with wrong password")
+	}
+
+	// tampered header (last salt byte)
+	d[len(k.header())-1]++
+	_, _, err = decryptVault(d, "pass")
+	if err == nil {
+		t.Fatal("expected decryption to fail with tampered header")
+	}
+}
+
+func TestFindEntries(t *testing.T) {
+	z := []zauth.ZAuth{
+		{ID: "0a1b2c3d-0000-4000-8000-000000000001", Issuer: "GitHub", Label: "GitHub:alice"},
+		{ID: "0a1b2c3d-0000-4000-8000-000000000002", Issuer: "Google", Label: "Google:alice@example.com"},
+		{ID: "12345678-0000-4000-8000-000000000003", Issuer: "Git", Label: "Git:bob"},
+	}
+
+	tests := []struct {
+		q   string
+		idx []int
+	}{
+		{"2", []int{1}},
+		{"4", []int{}},
+		{"0a1b2c3d-0000-4000-8000-000000000002", []int{1}},
+		{"0A1B2C3D", []int{0, 1}},
+		{"1234", []int{2}},
+		{"123", []int{}},
+		{"git", []int{2}},
+		{"GITHUB", []int{0}},
+		{"bob", []int{2}},
+		{"alice", []int{0}},
+		{"GitHub:alice", []int{0}},
+		{"example", []int{1}},
+		{"g", []int{0, 1, 2}},
+		{"git*", []int{0, 2}},
+		{"*@example.com", []int{1}},
+		{"[", []int{}},
+		{"gthb", []int{0}},
+		{"ggl alc", []int{1}},
+		{"gi", []int{0, 2}},
+		{"zzz", []int{}},
+		{"xyz", []int{}},
+		{"", []int{}},
+	}
+
+	for _, tc := range tests {
+		idx := FindEntries(z, tc.q)
+		if fmt.Sprint(idx) != fmt.Sprint(tc.idx) {
+			t.Fatalf("query %q: expected %v. received: %v", tc.q, tc.idx, idx)
+		}
+	}
 }
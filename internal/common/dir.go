@@ -0,0 +1,56 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// DirEnv is the environment variable overriding zauth data directory.
+const DirEnv = "ZAUTH_DIR"
+
+// ZAuthJsonName is the file name of zauth vault in data directory.
+const ZAuthJsonName = "zauth.json"
+
+// DataDir returns zauth data directory. It is resolved in following order:
+//   - dir (eg: from --dir flag), if not empty
+//   - ZAUTH_DIR environment variable
+//   - $HOME/.zauth, if it exists (used by older versions of zauth)
+//   - on linux: $XDG_CONFIG_HOME/zauth if it exists, else $XDG_DATA_HOME/zauth (default: $HOME/.local/share/zauth)
+//   - $HOME/.zauth
+func DataDir(dir string) (string, error) {
+	if dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	if d := os.Getenv(DirEnv); d != "" {
+		return filepath.Abs(d)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find zauth directory (set %s): %v", DirEnv, err)
+	}
+
+	legacy := filepath.Join(home, ".zauth")
+	if runtime.GOOS != "linux" || isDir(legacy) {
+		return legacy, nil
+	}
+
+	if c := os.Getenv("XDG_CONFIG_HOME"); c != "" && isDir(filepath.Join(c, "zauth")) {
+		return filepath.Join(c, "zauth"), nil
+	}
+
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		data = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(data, "zauth"), nil
+}
+
+func isDir(d string) bool {
+	fi, err := os.Stat(d)
+	return err == nil && fi.IsDir()
+}
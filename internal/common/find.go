@@ -0,0 +1,132 @@
+package common
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// MinIDPrefixLen is the minimum length of an entry ID prefix accepted by FindEntries.
+const MinIDPrefixLen = 4
+
+// FindEntries returns indexes of entries in z matching query q.
+// q is matched in following order:
+//   - 1-based index (as printed by entry -list)
+//   - entry ID or ID prefix (at least MinIDPrefixLen characters)
+//   - case-insensitive glob pattern on issuer, label or identifier (if q contains any of *?[)
+//   - case-insensitive issuer, label or identifier (exact match)
+//   - case-insensitive issuer or label (substring match)
+//   - case-insensitive issuer or label (fuzzy match: characters of q appear in order, eg: "gthb" matches GitHub)
+func FindEntries(z []zauth.ZAuth, q string) []int {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil
+	}
+
+	if n, err := strconv.Atoi(q); err == nil && n >= 1 && n <= len(z) {
+		return []int{n - 1}
+	}
+
+	q = strings.ToLower(q)
+	if len(q) >= MinIDPrefixLen && strings.Trim(q, "0123456789abcdef-") == "" {
+		idx := matchEntries(z, func(e *zauth.ZAuth) bool {
+			return strings.HasPrefix(strings.ToLower(e.ID), q)
+		})
+		if len(idx) > 0 {
+			return idx
+		}
+	}
+
+	if IsPattern(q) {
+		return matchEntries(z, func(e *zauth.ZAuth) bool {
+			for _, v := range []string{e.Issuer, e.Label, LabelIdentifier(e.Label)} {
+				if ok, _ := path.Match(q, strings.ToLower(v)); ok {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	idx := matchEntries(z, func(e *zauth.ZAuth) bool {
+		return strings.ToLower(e.Issuer) == q || strings.ToLower(e.Label) == q || strings.ToLower(LabelIdentifier(e.Label)) == q
+	})
+	if len(idx) > 0 {
+		return idx
+	}
+
+	idx = matchEntries(z, func(e *zauth.ZAuth) bool {
+		return strings.Contains(strings.ToLower(e.Issuer), q) || strings.Contains(strings.ToLower(e.Label), q)
+	})
+	if len(idx) > 0 {
+		return idx
+	}
+
+	return matchEntries(z, func(e *zauth.ZAuth) bool {
+		return fuzzyMatch(q, strings.ToLower(e.Issuer)) || fuzzyMatch(q, strings.ToLower(e.Label))
+	})
+}
+
+// fuzzyMatch reports whether all characters of q appear in s in the same order. Spaces in q are ignored.
+func fuzzyMatch(q string, s string) bool {
+	r := []rune(s)
+	i := 0
+	for _, c := range q {
+		if c == ' ' {
+			continue
+		}
+
+		for i < len(r) && r[i] != c {
+			i++
+		}
+		if i == len(r) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// FilterEntries returns indexes of entries in z whose issuer, label (including identifier) or a tag fuzzy matches query q
+// (case-insensitive), in order.
+// Unlike FindEntries, all matching entries are returned (eg: for incremental search or filtering listings). All entries match an empty query.
+func FilterEntries(z []zauth.ZAuth, q string) []int {
+	q = strings.ToLower(strings.TrimSpace(q))
+	return matchEntries(z, func(e *zauth.ZAuth) bool {
+		if fuzzyMatch(q, strings.ToLower(e.Issuer)) || fuzzyMatch(q, strings.ToLower(e.Label)) {
+			return true
+		}
+
+		for _, t := range e.Tags {
+			if fuzzyMatch(q, strings.ToLower(t)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// IsPattern reports whether query q is a glob pattern.
+func IsPattern(q string) bool {
+	return strings.ContainsAny(q, "*?[")
+}
+
+func matchEntries(z []zauth.ZAuth, m func(*zauth.ZAuth) bool) []int {
+	idx := make([]int, 0)
+	for i := range z {
+		if m(&z[i]) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// LabelIdentifier returns account identifier from label l, which is the part of label following issuer prefix.
+func LabelIdentifier(l string) string {
+	if i := strings.Index(l, ":"); i >= 0 {
+		return l[i+1:]
+	}
+	return l
+}
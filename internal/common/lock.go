@@ -0,0 +1,88 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockSuffix is appended to zauth.json path to get path of it's lock file.
+// zauth.json itself is not locked, as it is replaced on every write.
+const LockSuffix = ".lock"
+
+// LockTimeout is the maximum time to wait for a lock held by another zauth process.
+var LockTimeout = 10 * time.Second
+
+// lockRetryInterval is the time to wait between attempts to take a lock.
+const lockRetryInterval = 100 * time.Millisecond
+
+// ErrLocked is returned when store is locked by another process for longer than LockTimeout.
+var ErrLocked = errors.New("locked by another zauth process")
+
+// Lock takes exclusive advisory lock on store. It must be held while entries are read, modified and written back,
+// so that concurrent zauth processes do not overwrite each other's changes.
+// If lock is held by another process, Lock waits up to LockTimeout and returns ErrLocked.
+// Lock is reentrant: each call must be matched by a call to Unlock.
+func (s *Store) Lock() error {
+	if s.lock != nil {
+		s.locks++
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(s.Path), 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.Path+LockSuffix, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		if ok {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			f.Close()
+			return fmt.Errorf("%s: %w", s.Path, ErrLocked)
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+
+	s.lock = f
+	s.locks = 1
+	return nil
+}
+
+// Unlock releases lock taken by Lock.
+func (s *Store) Unlock() error {
+	if s.lock == nil {
+		return nil
+	}
+
+	s.locks--
+	if s.locks > 0 {
+		return nil
+	}
+
+	f := s.lock
+	s.lock = nil
+
+	err := unlockFile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
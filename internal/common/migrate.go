@@ -0,0 +1,190 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// SchemaVersion is the version of zauth.json format written by this version of zauth.
+//
+// Versions:
+//   - 0: bare array of entries
+//   - 1: object with entries and trash
+//   - 2: version and metadata (name, created/modified times) added
+//   - 3: entry tags added (previously kept in misc as andOTP tags or Aegis groups)
+//   - 4: entry notes, URL and custom metadata added (notes were previously kept in misc as Aegis note)
+const SchemaVersion = 4
+
+// ErrNewerSchema is returned when writing a file created by a newer version of zauth,
+// as writing it would lose data this version of zauth does not know about.
+var ErrNewerSchema = errors.New("file was written by a newer version of zauth. please upgrade zauth")
+
+// migration upgrades zauth.json document d from version n to n+1, where n is the migration's index in migrations.
+type migration func(d map[string]interface{}) error
+
+// migrations upgrade documents written by older versions of zauth. Version 0 (bare array) is wrapped before migrating.
+// To change zauth.json format, increment SchemaVersion and append a migration from previous version.
+// Migrations must not depend on code which may change later (eg: tag normalization), so that a version is always upgraded the same way.
+var migrations = []migration{
+	migrateV0,
+	migrateV1,
+	migrateV2,
+	migrateV3,
+}
+
+// migrateV0 upgrades bare array of entries (wrapped as entries by decodeVault) to an object with trash.
+func migrateV0(d map[string]interface{}) error {
+	d["trash"] = []interface{}{}
+	return nil
+}
+
+// migrateV1 adds metadata. Times are set on next write.
+func migrateV1(d map[string]interface{}) error {
+	d["meta"] = map[string]interface{}{}
+	return nil
+}
+
+// migrateV2 moves andOTP tags and Aegis groups of entries (and deleted entries) from misc to tags.
+// Tags are trimmed and empty tags and duplicates (case-insensitive) are dropped, as NormalizeTags did in version 3.
+func migrateV2(d map[string]interface{}) error {
+	for _, k := range []string{"entries", "trash"} {
+		l, _ := d[k].([]interface{})
+		for _, e := range l {
+			e, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			tags := make([]string, 0)
+			seen := make(map[string]bool)
+			if misc, ok := e["misc"].(map[string]interface{}); ok {
+				for _, mk := range []string{"tags", "groups"} {
+					if mt, ok := misc[mk].([]interface{}); ok {
+						for _, t := range mt {
+							s, ok := t.(string)
+							s = strings.TrimSpace(s)
+							if !ok || s == "" || seen[strings.ToLower(s)] {
+								continue
+							}
+
+							seen[strings.ToLower(s)] = true
+							tags = append(tags, s)
+						}
+					}
+					delete(misc, mk)
+				}
+			}
+			e["tags"] = tags
+		}
+	}
+	return nil
+}
+
+// migrateV3 moves Aegis notes of entries (and deleted entries) from misc to notes.
+func migrateV3(d map[string]interface{}) error {
+	for _, k := range []string{"entries", "trash"} {
+		l, _ := d[k].([]interface{})
+		for _, e := range l {
+			e, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if misc, ok := e["misc"].(map[string]interface{}); ok {
+				if n, ok := misc["note"].(string); ok {
+					e["notes"] = n
+				}
+				delete(misc, "note")
+			}
+		}
+	}
+	return nil
+}
+
+// decodeVault parses decrypted zauth.json content b, migrating it to SchemaVersion if it was written by an older version of zauth.
+// Returns vault and version of b.
+// Documents of newer versions are decoded as is (unknown fields are ignored), but must not be written back (see ErrNewerSchema).
+func decodeVault(b []byte) (*zauth.ZAuthVault, int, error) {
+	var raw interface{}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var d map[string]interface{}
+	switch r := raw.(type) {
+	case []interface{}:
+		d = map[string]interface{}{"version": float64(0), "entries": r}
+	case map[string]interface{}:
+		d = r
+	default:
+		return nil, 0, fmt.Errorf("invalid zauth.json content")
+	}
+
+	ver, err := documentVersion(d)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for n := ver; n < SchemaVersion; n++ {
+		err = migrations[n](d)
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to migrate zauth.json from version %d: %v", n, err)
+		}
+		d["version"] = float64(n + 1)
+	}
+
+	mb, err := json.Marshal(d)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	v := &zauth.ZAuthVault{}
+	err = json.Unmarshal(mb, v)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if v.Entries == nil {
+		v.Entries = make([]zauth.ZAuth, 0)
+	}
+
+	return v, ver, nil
+}
+
+// schemaVersion returns version of decrypted zauth.json content b.
+func schemaVersion(b []byte) (int, error) {
+	var raw interface{}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return 0, err
+	}
+
+	switch r := raw.(type) {
+	case []interface{}:
+		return 0, nil
+	case map[string]interface{}:
+		return documentVersion(r)
+	default:
+		return 0, fmt.Errorf("invalid zauth.json content")
+	}
+}
+
+// documentVersion returns version of document d. Documents without version were written by version 1.
+func documentVersion(d map[string]interface{}) (int, error) {
+	v, ok := d["version"]
+	if !ok {
+		return 1, nil
+	}
+
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return 0, fmt.Errorf("invalid zauth.json version: %v", v)
+	}
+
+	return int(f), nil
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// Sort orders supported by SortEntries. Usage is stored in entry Misc, using the same keys as andOTP,
+// so that usage of imported entries is kept and exported back.
+const (
+	SortNone      = ""          // vault order
+	SortIssuer    = "issuer"    // issuer, then label (case-insensitive)
+	SortLabel     = "label"     // label (case-insensitive)
+	SortRecent    = "recent"    // most recently used first
+	SortFrequency = "frequency" // most frequently used first
+
+	MiscLastUsed      = "last_used"      // last use time (unix milliseconds)
+	MiscUsedFrequency = "used_frequency" // number of uses
+)
+
+var SupportedSortOrders = []string{SortIssuer, SortLabel, SortRecent, SortFrequency}
+
+// ValidateSort returns an error if s is not a supported sort order. An empty order is valid (vault order).
+func ValidateSort(s string) error {
+	if s == SortNone {
+		return nil
+	}
+
+	for _, o := range SupportedSortOrders {
+		if s == o {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid sort order: %s (supported: %s)", s, strings.Join(SupportedSortOrders, ", "))
+}
+
+// SortEntries sorts indexes idx of entries in z by order s. Entries which compare equal keep their order.
+func SortEntries(z []zauth.ZAuth, idx []int, s string) error {
+	err := ValidateSort(s)
+	if err != nil {
+		return err
+	}
+
+	var less func(a, b *zauth.ZAuth) bool
+	switch s {
+	case SortNone:
+		return nil
+	case SortIssuer:
+		less = func(a, b *zauth.ZAuth) bool {
+			ai, bi := strings.ToLower(a.Issuer), strings.ToLower(b.Issuer)
+			if ai != bi {
+				return ai < bi
+			}
+			return strings.ToLower(a.Label) < strings.ToLower(b.Label)
+		}
+	case SortLabel:
+		less = func(a, b *zauth.ZAuth) bool {
+			return strings.ToLower(a.Label) < strings.ToLower(b.Label)
+		}
+	case SortRecent:
+		less = func(a, b *zauth.ZAuth) bool {
+			return LastUsed(a) > LastUsed(b)
+		}
+	case SortFrequency:
+		less = func(a, b *zauth.ZAuth) bool {
+			return UsedFrequency(a) > UsedFrequency(b)
+		}
+	}
+
+	sort.SliceStable(idx, func(i, j int) bool {
+		return less(&z[idx[i]], &z[idx[j]])
+	})
+	return nil
+}
+
+// RecordUsage records use of entry z at time t (eg: when it's code is printed or copied).
+func RecordUsage(z *zauth.ZAuth, t time.Time) {
+	if z.Misc == nil {
+		z.Misc = make(map[string]interface{})
+	}
+
+	z.Misc[MiscLastUsed] = t.UnixNano() / int64(time.Millisecond)
+	z.Misc[MiscUsedFrequency] = UsedFrequency(z) + 1
+}
+
+// LastUsed returns last use time (unix milliseconds) of entry z, or 0 if it was never used.
+func LastUsed(z *zauth.ZAuth) int64 {
+	return miscInt64(z.Misc, MiscLastUsed)
+}
+
+// UsedFrequency returns the number of uses of entry z.
+func UsedFrequency(z *zauth.ZAuth) int64 {
+	return miscInt64(z.Misc, MiscUsedFrequency)
+}
+
+// miscInt64 returns number stored in m under key k. Numbers are float64 when read from zauth.json.
+func miscInt64(m map[string]interface{}, k string) int64 {
+	switch v := m[k].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
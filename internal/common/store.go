@@ -0,0 +1,408 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// Store reads and writes zauth entries. zauth.json is always written as an encrypted vault.
+// Vault password is read with PasswordReader on first access and the derived key is kept for the lifetime of Store.
+type Store struct {
+	Dir  string // data directory (see DataDir). Exported files are written here
+	Path string // vault path (zauth.json for default vault)
+	Name string // vault name (saved in vault metadata)
+	pr   PasswordReader
+	key  *vaultKey
+
+	ver  int         // schema version of zauth.json, as last read
+	seen os.FileInfo // zauth.json, as last read (nil if it was not read)
+
+	lock  *os.File // lock file, while store is locked
+	locks int      // number of Lock calls not yet matched by Unlock
+}
+
+// NewStore returns Store for zauth.json in data directory dir (see DataDir). Password reader pr is used to unlock vault.
+func NewStore(dir string, pr PasswordReader) *Store {
+	return &Store{
+		Dir:  dir,
+		Path: filepath.Join(dir, ZAuthJsonName),
+		Name: DefaultVault,
+		pr:   pr,
+	}
+}
+
+// WriteZAuthJson writes ZAuth array objects to zauth.json vault.
+// File is overwritten with new content if ow is true. Else entries in z are appended to existing file.
+// Trash is preserved in both cases.
+func (s *Store) WriteZAuthJson(z []zauth.ZAuth, ow bool) error {
+	err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	v, err := s.Read()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		v = &zauth.ZAuthVault{}
+	}
+
+	if ow {
+		v.Entries = z
+	} else {
+		v.Entries = append(v.Entries, z...)
+	}
+
+	return s.Write(v)
+}
+
+// ReadZAuthJson reads zauth.json vault and returns it's equivalent ZAuth array object.
+func (s *Store) ReadZAuthJson() ([]zauth.ZAuth, error) {
+	v, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Entries, nil
+}
+
+// Read reads and decrypts zauth.json vault.
+// If zauth.json is an unencrypted (legacy) file, it is encrypted in place with a new password.
+// Entries without ID (written by older versions of zauth) are assigned one, which is saved immediately so that IDs are stable.
+// A file which needs to be saved is read again once store is locked, so that changes of another process are not lost.
+func (s *Store) Read() (*zauth.ZAuthVault, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	legacy := !IsVault(b)
+	if !legacy {
+		b, err = s.decrypt(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v, ver, err := decodeVault(b)
+	if err != nil {
+		return nil, err
+	}
+	s.ver, s.seen = ver, fi
+
+	ids, err := assignIDs(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// files written by older versions of zauth are migrated and saved
+	if legacy || ids || ver < SchemaVersion {
+		if legacy && s.key == nil {
+			fmt.Fprintf(os.Stderr, "%s is not encrypted. Please set a password to encrypt it.\n", s.Path)
+		}
+
+		if s.lock == nil {
+			err = s.lockWrite()
+			if err != nil {
+				return nil, err
+			}
+			defer s.Unlock()
+
+			return s.Read()
+		}
+
+		// no backup is kept for legacy file, as it would contain unencrypted secrets
+		err = s.write(v, !legacy)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update %s: %v", s.Path, err)
+		}
+	}
+
+	return v, nil
+}
+
+// Write encrypts vault v and writes it to zauth.json.
+// zauth.json is replaced atomically and it's previous version is kept as zauth.json.bak.
+func (s *Store) Write(v *zauth.ZAuthVault) error {
+	return s.write(v, true)
+}
+
+// WriteUsage encrypts vault v and writes it to zauth.json, without replacing zauth.json.bak.
+// It is used for usage tracking (see RecordUsage), so that showing codes does not rotate out the backup of the last change.
+func (s *Store) WriteUsage(v *zauth.ZAuthVault) error {
+	return s.write(v, false)
+}
+
+// WritePurge encrypts vault v and writes it to zauth.json, removing zauth.json.bak.
+// It is used when deleted entries are purged, since backup would still hold their secrets.
+func (s *Store) WritePurge(v *zauth.ZAuthVault) error {
+	err := s.write(v, false)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(s.Path + BackupSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// write encrypts vault v and atomically writes it to zauth.json. Previous version is kept if bak is true.
+func (s *Store) write(v *zauth.ZAuthVault, bak bool) error {
+	err := os.MkdirAll(filepath.Dir(s.Path), 0700)
+	if err != nil {
+		return err
+	}
+
+	if s.key == nil {
+		err = s.unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.checkSchema()
+	if err != nil {
+		return err
+	}
+
+	if v.Entries == nil {
+		v.Entries = make([]zauth.ZAuth, 0)
+	}
+	if v.Trash == nil {
+		v.Trash = make([]zauth.ZAuthDeleted, 0)
+	}
+
+	_, err = assignIDs(v)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	v.Version = SchemaVersion
+	v.Meta.Name = s.Name
+	if v.Meta.Created == 0 {
+		v.Meta.Created = now
+	}
+	v.Meta.Modified = now
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	b, err = encryptVault(b, s.key)
+	if err != nil {
+		return err
+	}
+
+	if bak {
+		err = writeFileBackup(s.Path, b, 0600)
+	} else {
+		err = writeFileAtomic(s.Path, b, 0600)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.ver = SchemaVersion
+	s.seen, err = os.Stat(s.Path)
+	return err
+}
+
+// assignIDs assigns a new ID to entries (and deleted entries) of v having no ID or an ID already used by another entry.
+// Reports whether any ID was assigned.
+func assignIDs(v *zauth.ZAuthVault) (bool, error) {
+	seen := make(map[string]bool)
+	assigned := false
+
+	assign := func(z *zauth.ZAuth) error {
+		if z.ID == "" || seen[z.ID] {
+			id, err := zauth.NewID()
+			if err != nil {
+				return err
+			}
+			z.ID = id
+			assigned = true
+		}
+		seen[z.ID] = true
+		return nil
+	}
+
+	for i := range v.Entries {
+		if err := assign(&v.Entries[i]); err != nil {
+			return false, err
+		}
+	}
+
+	for i := range v.Trash {
+		if err := assign(&v.Trash[i].ZAuth); err != nil {
+			return false, err
+		}
+	}
+
+	return assigned, nil
+}
+
+// checkSchema returns ErrNewerSchema if existing zauth.json was written by a newer version of zauth.
+// Version seen by Read is used if zauth.json was not changed since, so that it is not decrypted again on every write.
+func (s *Store) checkSchema() error {
+	fi, err := os.Stat(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ver := s.ver
+	if s.seen == nil || !os.SameFile(s.seen, fi) || !s.seen.ModTime().Equal(fi.ModTime()) || s.seen.Size() != fi.Size() {
+		ver, err = s.readSchemaVersion()
+		if err != nil {
+			return err
+		}
+	}
+
+	if ver > SchemaVersion {
+		return fmt.Errorf("%s (version %d): %w", s.Path, ver, ErrNewerSchema)
+	}
+	return nil
+}
+
+// readSchemaVersion reads and decrypts zauth.json and returns it's schema version.
+func (s *Store) readSchemaVersion() (int, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	// unencrypted files are only written by older versions of zauth
+	if !IsVault(b) {
+		return 0, nil
+	}
+
+	b, err = s.decrypt(b)
+	if err != nil {
+		return 0, err
+	}
+	return schemaVersion(b)
+}
+
+// lockWrite locks store for writing once vault key is known, so that password is never prompted while store is locked
+// (which would block other zauth processes up to LockTimeout). New vaults are assigned a password first.
+func (s *Store) lockWrite() error {
+	if s.key == nil && s.lock == nil {
+		err := s.unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return s.Lock()
+}
+
+// unlock prepares vault key for writing.
+// If zauth.json is an existing vault, it's password is verified. Else a new password is set.
+func (s *Store) unlock() error {
+	b, err := os.ReadFile(s.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err == nil && IsVault(b) {
+		_, err = s.decrypt(b)
+		return err
+	}
+
+	pwd, err := s.readNewPassword()
+	if err != nil {
+		return err
+	}
+
+	s.key, err = newVaultKey(pwd)
+	return err
+}
+
+// decrypt decrypts vault content b, reading vault password if key is not yet known.
+func (s *Store) decrypt(b []byte) ([]byte, error) {
+	if s.key != nil {
+		k, _, err := parseVaultHeader(b)
+		if err != nil {
+			return nil, err
+		}
+
+		// reuse cached password-derived key when header parameters are unchanged
+		if string(k.header()) == string(s.key.header()) {
+			pt, err := openVault(b, s.key)
+			if err == nil {
+				return pt, nil
+			}
+		}
+	}
+
+	pwd, err := s.readPassword("Vault password: ")
+	if err != nil {
+		return nil, err
+	}
+
+	pt, k, err := decryptVault(b, pwd)
+	if err != nil {
+		return nil, err
+	}
+
+	s.key = k
+	return pt, nil
+}
+
+// readPassword reads vault password after printing prompt p.
+// Prompt is written to stderr, so that stdout only holds command output (eg: json).
+func (s *Store) readPassword(p string) (string, error) {
+	if pwd, ok := s.pr.(StaticPassword); ok {
+		return string(pwd), nil
+	}
+
+	fmt.Fprint(os.Stderr, p)
+	pwd, err := s.pr.ReadPassword()
+	fmt.Fprintln(os.Stderr)
+	return pwd, err
+}
+
+// readNewPassword reads and confirms password for a new vault. A StaticPassword is used as is, without confirmation.
+func (s *Store) readNewPassword() (string, error) {
+	pwd, err := s.readPassword("New vault password: ")
+	if err != nil {
+		return "", err
+	}
+
+	if pwd == "" {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+
+	if _, ok := s.pr.(StaticPassword); ok {
+		return pwd, nil
+	}
+
+	cnf, err := s.readPassword("Confirm vault password: ")
+	if err != nil {
+		return "", err
+	}
+
+	if pwd != cnf {
+		return "", fmt.Errorf("passwords do not match")
+	}
+
+	return pwd, nil
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// ParseTags returns tags of comma separated list s (eg: "work, personal").
+func ParseTags(s string) []string {
+	return NormalizeTags(strings.Split(s, ","))
+}
+
+// NormalizeTags returns tags t without surrounding spaces, empty tags and duplicates (case-insensitive). First occurrence is kept.
+func NormalizeTags(t []string) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0, len(t))
+	for _, s := range t {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[strings.ToLower(s)] {
+			continue
+		}
+
+		seen[strings.ToLower(s)] = true
+		tags = append(tags, s)
+	}
+	return tags
+}
+
+// HasTag reports whether entry z has tag t (case-insensitive).
+func HasTag(z *zauth.ZAuth, t string) bool {
+	for _, s := range z.Tags {
+		if strings.EqualFold(s, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTags adds tags t to entry z. Reports whether z was changed.
+func AddTags(z *zauth.ZAuth, t []string) bool {
+	n := len(z.Tags)
+	z.Tags = NormalizeTags(append(z.Tags, t...))
+	return len(z.Tags) != n
+}
+
+// RemoveTags removes tags t (case-insensitive) from entry z. Reports whether z was changed.
+func RemoveTags(z *zauth.ZAuth, t []string) bool {
+	tags := make([]string, 0, len(z.Tags))
+	for _, s := range z.Tags {
+		keep := true
+		for _, r := range t {
+			if strings.EqualFold(s, strings.TrimSpace(r)) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			tags = append(tags, s)
+		}
+	}
+
+	changed := len(tags) != len(z.Tags)
+	z.Tags = tags
+	return changed
+}
+
+// FilterTag returns indexes idx of entries in z having tag t, in order. All indexes are returned if t is empty.
+func FilterTag(z []zauth.ZAuth, idx []int, t string) []int {
+	if strings.TrimSpace(t) == "" {
+		return idx
+	}
+
+	f := make([]int, 0, len(idx))
+	for _, i := range idx {
+		if HasTag(&z[i], strings.TrimSpace(t)) {
+			f = append(f, i)
+		}
+	}
+	return f
+}
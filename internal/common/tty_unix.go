@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package common
+
+import "os"
+
+// openTTY opens controlling terminal of the process, for reading passwords when stdin is not a terminal.
+func openTTY() (*os.File, error) {
+	return os.Open("/dev/tty")
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package common
+
+import "os"
+
+// openTTY opens console input of the process, for reading passwords when stdin is not a terminal.
+func openTTY() (*os.File, error) {
+	return os.OpenFile("CONIN$", os.O_RDWR, 0)
+}
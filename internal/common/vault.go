@@ -0,0 +1,196 @@
+package common
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Vault file layout (all integers are big-endian):
+//
+//	magic (8) | version (1) | kdf (1) | time (4) | memory (4) | threads (1) | salt length (1) | salt | nonce (12) | ciphertext
+//
+// Everything before the ciphertext is authenticated as additional data.
+const vaultMagic = "ZAUTHVLT"
+const vaultVersion = 1
+const vaultKdfArgon2id = 1
+const vaultKeyLen = 32
+const vaultSaltLen = 16
+
+// Argon2id parameters used for newly created vaults.
+// Parameters are stored in the vault header so they can be changed without breaking existing files.
+var vaultArgon2Time uint32 = 3
+var vaultArgon2Memory uint32 = 64 * 1024
+var vaultArgon2Threads uint8 = 4
+
+// Upper bounds for Argon2id parameters read from vault header.
+const vaultArgon2MaxTime = 64
+const vaultArgon2MaxMemory = 4 * 1024 * 1024
+
+var ErrVaultPassword = errors.New("incorrect password or corrupted vault")
+var ErrVaultFormat = errors.New("invalid or unsupported vault format")
+
+// vaultKey holds a password-derived key and the parameters used to derive it.
+type vaultKey struct {
+	salt    []byte
+	time    uint32
+	memory  uint32
+	threads uint8
+	key     []byte
+}
+
+// newVaultKey derives a new key from password pwd with a random salt.
+func newVaultKey(pwd string) (*vaultKey, error) {
+	salt := make([]byte, vaultSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	k := &vaultKey{
+		salt:    salt,
+		time:    vaultArgon2Time,
+		memory:  vaultArgon2Memory,
+		threads: vaultArgon2Threads,
+	}
+	k.derive(pwd)
+	return k, nil
+}
+
+func (k *vaultKey) derive(pwd string) {
+	k.key = argon2.IDKey([]byte(pwd), k.salt, k.time, k.memory, k.threads, vaultKeyLen)
+}
+
+func (k *vaultKey) header() []byte {
+	var b bytes.Buffer
+	b.WriteString(vaultMagic)
+	b.WriteByte(vaultVersion)
+	b.WriteByte(vaultKdfArgon2id)
+	binary.Write(&b, binary.BigEndian, k.time)
+	binary.Write(&b, binary.BigEndian, k.memory)
+	b.WriteByte(k.threads)
+	b.WriteByte(byte(len(k.salt)))
+	b.Write(k.salt)
+	return b.Bytes()
+}
+
+// IsVault reports whether d starts with a zauth vault header.
+func IsVault(d []byte) bool {
+	return bytes.HasPrefix(d, []byte(vaultMagic))
+}
+
+// parseVaultHeader parses vault header from d.
+// Returns key parameters (without derived key) and the remaining bytes (nonce and ciphertext).
+func parseVaultHeader(d []byte) (*vaultKey, []byte, error) {
+	if !IsVault(d) {
+		return nil, nil, ErrVaultFormat
+	}
+
+	r := bytes.NewReader(d[len(vaultMagic):])
+	var version, kdf, saltLen byte
+	k := &vaultKey{}
+
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, nil, ErrVaultFormat
+	}
+	if version != vaultVersion {
+		return nil, nil, fmt.Errorf("unsupported vault version: %d", version)
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &kdf); err != nil {
+		return nil, nil, ErrVaultFormat
+	}
+	if kdf != vaultKdfArgon2id {
+		return nil, nil, fmt.Errorf("unsupported vault key derivation: %d", kdf)
+	}
+
+	for _, v := range []interface{}{&k.time, &k.memory, &k.threads, &saltLen} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return nil, nil, ErrVaultFormat
+		}
+	}
+
+	if k.time == 0 || k.time > vaultArgon2MaxTime || k.memory == 0 || k.memory > vaultArgon2MaxMemory || k.threads == 0 || saltLen == 0 {
+		return nil, nil, ErrVaultFormat
+	}
+
+	k.salt = make([]byte, saltLen)
+	if _, err := io.ReadFull(r, k.salt); err != nil {
+		return nil, nil, ErrVaultFormat
+	}
+
+	return k, d[len(d)-r.Len():], nil
+}
+
+// encryptVault encrypts d with key k and returns vault file content.
+func encryptVault(d []byte, k *vaultKey) ([]byte, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	hdr := append(k.header(), nonce...)
+	return gcm.Seal(hdr, nonce, d, hdr), nil
+}
+
+// decryptVault decrypts vault file content d with password pwd.
+// Returns decrypted content and the key derived from pwd, which can be used for re-encrypting the vault.
+func decryptVault(d []byte, pwd string) ([]byte, *vaultKey, error) {
+	k, _, err := parseVaultHeader(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	k.derive(pwd)
+
+	pt, err := openVault(d, k)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pt, k, nil
+}
+
+// openVault decrypts vault file content d with already derived key k.
+func openVault(d []byte, k *vaultKey) ([]byte, error) {
+	_, rest, err := parseVaultHeader(d)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrVaultFormat
+	}
+
+	nonce := rest[:gcm.NonceSize()]
+	hdr := d[:len(d)-len(rest)+gcm.NonceSize()]
+	pt, err := gcm.Open(nil, nonce, rest[gcm.NonceSize():], hdr)
+	if err != nil {
+		return nil, ErrVaultPassword
+	}
+
+	return pt, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	blk, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(blk)
+}
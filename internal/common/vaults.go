@@ -0,0 +1,234 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// DefaultVault is the name of the vault stored in zauth.json.
+const DefaultVault = "default"
+
+// VaultsDirName is the directory (in data directory) containing named vaults.
+const VaultsDirName = "vaults"
+
+// vaultExt is the file extension of named vaults.
+const vaultExt = ".json"
+
+var vaultNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrVaultNotExist is returned when a named vault does not exist.
+var ErrVaultNotExist = errors.New("vault does not exist")
+
+// ErrVaultExist is returned when creating or renaming to a vault that already exists.
+var ErrVaultExist = errors.New("vault already exists")
+
+// VaultPath returns path of vault name in data directory dir.
+// Default vault is stored in zauth.json. Named vaults are stored in vaults/<name>.json.
+func VaultPath(dir string, name string) (string, error) {
+	if name == "" || name == DefaultVault {
+		return filepath.Join(dir, ZAuthJsonName), nil
+	}
+
+	if !vaultNameRe.MatchString(name) || strings.HasSuffix(name, vaultExt) {
+		return "", fmt.Errorf("invalid vault name: %q (allowed: letters, digits, '.', '_', '-')", name)
+	}
+
+	return filepath.Join(dir, VaultsDirName, name+vaultExt), nil
+}
+
+// NewVaultStore returns Store for vault name in data directory dir. Password reader pr is used to unlock vault.
+// Vault is not required to exist.
+func NewVaultStore(dir string, name string, pr PasswordReader) (*Store, error) {
+	p, err := VaultPath(dir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewStore(dir, pr)
+	s.Path = p
+	if name != "" {
+		s.Name = name
+	}
+	return s, nil
+}
+
+// VaultExists reports whether vault name exists in data directory dir.
+func VaultExists(dir string, name string) (bool, error) {
+	p, err := VaultPath(dir, name)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ListVaults returns names of vaults in data directory dir, sorted by name. Default vault is listed first if it exists.
+func ListVaults(dir string) ([]string, error) {
+	names := make([]string, 0)
+
+	ok, err := VaultExists(dir, DefaultVault)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		names = append(names, DefaultVault)
+	}
+
+	fl, err := os.ReadDir(filepath.Join(dir, VaultsDirName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	named := make([]string, 0, len(fl))
+	for _, f := range fl {
+		n := strings.TrimSuffix(f.Name(), vaultExt)
+		if f.IsDir() || n == f.Name() || !vaultNameRe.MatchString(n) {
+			continue
+		}
+		named = append(named, n)
+	}
+	sort.Strings(named)
+
+	return append(names, named...), nil
+}
+
+// CreateVault creates empty vault name in data directory dir. Vault password is read with pr.
+func CreateVault(dir string, name string, pr PasswordReader) error {
+	s, err := NewVaultStore(dir, name, pr)
+	if err != nil {
+		return err
+	}
+
+	exists := func() error {
+		ok, err := VaultExists(dir, name)
+		if err == nil && ok {
+			err = fmt.Errorf("%s: %w", name, ErrVaultExist)
+		}
+		return err
+	}
+
+	// existence is checked before new password is read, and again once locked
+	err = exists()
+	if err != nil {
+		return err
+	}
+
+	err = s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer s.Unlock()
+
+	err = exists()
+	if err != nil {
+		return err
+	}
+
+	return s.Write(&zauth.ZAuthVault{})
+}
+
+// RemoveVault removes named vault name (and it's backup) from data directory dir.
+// Default vault cannot be removed.
+func RemoveVault(dir string, name string) error {
+	if name == "" || name == DefaultVault {
+		return fmt.Errorf("default vault cannot be removed")
+	}
+
+	s, err := NewVaultStore(dir, name, nil)
+	if err != nil {
+		return err
+	}
+
+	err = s.Lock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.Unlock()
+		os.Remove(s.Path + LockSuffix)
+	}()
+
+	err = os.Remove(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", name, ErrVaultNotExist)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(s.Path + BackupSuffix)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RenameVault renames named vault old to new in data directory dir. Default vault cannot be renamed.
+func RenameVault(dir string, old string, new string) error {
+	if old == "" || old == DefaultVault || new == "" || new == DefaultVault {
+		return fmt.Errorf("default vault cannot be renamed")
+	}
+
+	olds, err := NewVaultStore(dir, old, nil)
+	if err != nil {
+		return err
+	}
+
+	news, err := NewVaultStore(dir, new, nil)
+	if err != nil {
+		return err
+	}
+
+	err = olds.Lock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		olds.Unlock()
+		os.Remove(olds.Path + LockSuffix)
+	}()
+
+	err = news.Lock()
+	if err != nil {
+		return err
+	}
+	defer news.Unlock()
+
+	ok, err := VaultExists(dir, old)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s: %w", old, ErrVaultNotExist)
+	}
+
+	ok, err = VaultExists(dir, new)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("%s: %w", new, ErrVaultExist)
+	}
+
+	err = os.Rename(olds.Path, news.Path)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(olds.Path+BackupSuffix, news.Path+BackupSuffix)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -1,16 +1,25 @@
 package oauthurl
 
 import (
+	"encoding/base32"
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/grijul/zauth/internal/zauth"
 )
 
+// Parse parses otpauth:// URI u as described in Google Authenticator Key URI format
+// (https://github.com/google/google-authenticator/wiki/Key-Uri-Format) and returns equivalent ZAuth object.
+//
+// Optional parameters default to zauth defaults (sha1, 6 digits, 30 seconds period).
+// Steam Guard entries (otpauth://steam/...) always have 5 digits.
+// Issuer is taken from issuer parameter if present, else from label prefix (Issuer:account).
+// Returned label is always in Issuer:account format if issuer is known.
 func Parse(u string) (*zauth.ZAuth, error) {
 
-	ourl, err := url.Parse(u)
+	ourl, err := url.Parse(strings.TrimSpace(u))
 	if err != nil {
 		return nil, err
 	}
@@ -20,32 +29,172 @@ func Parse(u string) (*zauth.ZAuth, error) {
 	}
 
 	za := &zauth.ZAuth{}
-	za.Type = ourl.Host
-	za.Label = ourl.Path[1:]
+	za.Type = strings.ToLower(ourl.Host)
+	if za.Type != "totp" && za.Type != "hotp" && za.Type != "steam" {
+		return nil, fmt.Errorf("invalid type: %s", ourl.Host)
+	}
+
+	lbl := strings.TrimPrefix(ourl.Path, "/")
+	if lbl == "" {
+		return nil, fmt.Errorf("missing label")
+	}
 
 	values := ourl.Query()
-	za.Secret = values.Get("secret")
-	za.Algorithm = values.Get("algorithm")
 
-	za.Digits, err = strconv.Atoi(values.Get("digits"))
+	// issuer parameter is stripped from label as is, as it may contain colons (see Format)
+	var lblIssuer, account string
+	if iss := strings.TrimSpace(values.Get("issuer")); iss != "" && strings.HasPrefix(lbl, iss+":") {
+		lblIssuer = iss
+		account = strings.TrimSpace(lbl[len(iss)+1:])
+	} else if i := strings.Index(lbl, ":"); i >= 0 {
+		lblIssuer = strings.TrimSpace(lbl[:i])
+		account = strings.TrimSpace(lbl[i+1:])
+	} else {
+		account = strings.TrimSpace(lbl)
+	}
+
+	if account == "" {
+		return nil, fmt.Errorf("missing account name in label")
+	}
+
+	za.Issuer = strings.TrimSpace(values.Get("issuer"))
+	if za.Issuer == "" {
+		za.Issuer = lblIssuer
+	}
+
+	if za.Issuer != "" {
+		za.Label = fmt.Sprintf("%s:%s", za.Issuer, account)
+	} else {
+		za.Label = account
+	}
+
+	za.Secret, err = parseSecret(values.Get("secret"))
 	if err != nil {
-		return nil, fmt.Errorf("invalid parameter: digits - " + err.Error())
+		return nil, err
 	}
 
-	za.Issuer = values.Get("issuer")
+	za.Algorithm = strings.ToUpper(values.Get("algorithm"))
+	if za.Algorithm == "" {
+		za.Algorithm = strings.ToUpper(zauth.DefaultAlgo)
+	}
+
+	if za.Algorithm != "SHA1" && za.Algorithm != "SHA256" && za.Algorithm != "SHA512" {
+		return nil, fmt.Errorf("invalid parameter: algorithm - %s", za.Algorithm)
+	}
+
+	// HOTP codes are only generated with SHA1
+	if za.Type == "hotp" && za.Algorithm != "SHA1" {
+		return nil, fmt.Errorf("invalid parameter: algorithm - %s (hotp only supports SHA1)", za.Algorithm)
+	}
+
+	za.Digits = zauth.DefaultDigits
+	if za.Type == "steam" {
+		za.Digits = zauth.SteamDigits
+	} else if d := values.Get("digits"); d != "" {
+		za.Digits, err = strconv.Atoi(d)
+		if err != nil || za.Digits < 1 || za.Digits > 10 {
+			return nil, fmt.Errorf("invalid parameter: digits - %s", d)
+		}
+	}
 
-	if za.Type == "totp" {
-		za.Period, err = strconv.ParseInt(values.Get("period"), 10, 0)
-		if err != nil {
-			return nil, fmt.Errorf("invalid parameter: period - " + err.Error())
+	if za.Type != "hotp" {
+		za.Period = zauth.DefaultPeriod
+		if p := values.Get("period"); p != "" {
+			za.Period, err = strconv.ParseInt(p, 10, 64)
+			if err != nil || za.Period <= 0 {
+				return nil, fmt.Errorf("invalid parameter: period - %s", p)
+			}
 		}
 
 	} else {
-		za.Counter, err = strconv.ParseInt(values.Get("counter"), 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("invalid parameter: counter - " + err.Error())
+		c := values.Get("counter")
+		if c == "" {
+			return nil, fmt.Errorf("missing parameter: counter (required for hotp)")
+		}
+
+		za.Counter, err = strconv.ParseInt(c, 10, 64)
+		if err != nil || za.Counter < 0 {
+			return nil, fmt.Errorf("invalid parameter: counter - %s", c)
 		}
 	}
 
 	return za, nil
 }
+
+// parseSecret validates base32 secret s and returns it upper-cased and padded.
+func parseSecret(s string) (string, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if s == "" {
+		return "", fmt.Errorf("missing parameter: secret")
+	}
+
+	if r := len(s) % 8; r != 0 {
+		s += strings.Repeat("=", 8-r)
+	}
+
+	_, err := base32.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid parameter: secret - invalid base32 secret")
+	}
+
+	return s, nil
+}
+
+// Format returns otpauth:// URI for entry z. It is the inverse of Parse.
+// Label is written in Issuer:account format and secret is written without base32 padding.
+func Format(z *zauth.ZAuth) string {
+	typ := strings.ToLower(z.Type)
+	if typ == "" {
+		typ = zauth.DefaultType
+	}
+
+	account := z.Label
+	if z.Issuer != "" && strings.HasPrefix(account, z.Issuer+":") {
+		account = account[len(z.Issuer)+1:]
+	} else if i := strings.Index(account, ":"); i >= 0 {
+		account = account[i+1:]
+	}
+
+	lbl := url.PathEscape(strings.TrimSpace(account))
+	if z.Issuer != "" {
+		lbl = pathEscape(z.Issuer) + ":" + lbl
+	}
+
+	algo := strings.ToUpper(z.Algorithm)
+	if algo == "" {
+		algo = strings.ToUpper(zauth.DefaultAlgo)
+	}
+
+	digits := z.Digits
+	if digits == 0 {
+		digits = zauth.DefaultDigits
+	}
+
+	q := []string{"secret=" + queryEscape(strings.TrimRight(strings.ToUpper(z.Secret), "="))}
+	if z.Issuer != "" {
+		q = append(q, "issuer="+queryEscape(z.Issuer))
+	}
+	q = append(q, "algorithm="+algo, fmt.Sprintf("digits=%d", digits))
+
+	if typ == "hotp" {
+		q = append(q, fmt.Sprintf("counter=%d", z.Counter))
+	} else {
+		period := z.Period
+		if period == 0 {
+			period = zauth.DefaultPeriod
+		}
+		q = append(q, fmt.Sprintf("period=%d", period))
+	}
+
+	return fmt.Sprintf("otpauth://%s/%s?%s", typ, lbl, strings.Join(q, "&"))
+}
+
+// pathEscape escapes issuer s for use in URI label. Colons are escaped, so that the label separator is the only colon of label.
+func pathEscape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), ":", "%3A")
+}
+
+// queryEscape escapes s for use in URI query. Spaces are escaped as %20, which is understood by all authenticator apps.
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -56,3 +56,139 @@ func TestParseUrl(t *testing.T) {
 		t.Fatal("incorrect counter: ", z.Counter)
 	}
 }
+
+func TestParseUrlDefaults(t *testing.T) {
+	z, err := Parse("otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if z.Digits != 6 || z.Period != 30 || z.Algorithm != "SHA1" || z.Counter != 0 {
+		t.Fatal("incorrect defaults: ", z)
+	}
+
+	if z.Secret != "JBSWY3DPEHPK3PXP" {
+		t.Fatal("incorrect secret: ", z.Secret)
+	}
+
+	// unpadded secret is padded
+	z, err = Parse("otpauth://totp/Example:alice@google.com?secret=jbswy3dpehpk3pxpjbswy3dpeh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if z.Secret != "JBSWY3DPEHPK3PXPJBSWY3DPEH======" {
+		t.Fatal("incorrect secret: ", z.Secret)
+	}
+}
+
+func TestParseUrlSteam(t *testing.T) {
+	z, err := Parse("otpauth://steam/Steam:alice?secret=JBSWY3DPEHPK3PXP&issuer=Steam&digits=6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if z.Type != "steam" || z.Digits != 5 || z.Period != 30 || z.Algorithm != "SHA1" {
+		t.Fatal("incorrect steam entry: ", z)
+	}
+
+	u := Format(z)
+	if u != "otpauth://steam/Steam:alice?secret=JBSWY3DPEHPK3PXP&issuer=Steam&algorithm=SHA1&digits=5&period=30" {
+		t.Fatal("incorrect url: ", u)
+	}
+}
+
+func TestParseUrlLabel(t *testing.T) {
+	tests := []struct {
+		url    string
+		issuer string
+		label  string
+	}{
+		// issuer from label prefix
+		{"otpauth://totp/ACME%20Co:john.doe@email.com?secret=JBSWY3DPEHPK3PXP", "ACME Co", "ACME Co:john.doe@email.com"},
+		// url-encoded colon and space after colon
+		{"otpauth://totp/ACME%20Co%3A%20john@email.com?secret=JBSWY3DPEHPK3PXP", "ACME Co", "ACME Co:john@email.com"},
+		// issuer parameter only
+		{"otpauth://totp/john@email.com?secret=JBSWY3DPEHPK3PXP&issuer=ACME%20Co", "ACME Co", "ACME Co:john@email.com"},
+		// issuer parameter takes precedence over label prefix
+		{"otpauth://totp/Old:john@email.com?secret=JBSWY3DPEHPK3PXP&issuer=New", "New", "New:john@email.com"},
+		// no issuer
+		{"otpauth://totp/john@email.com?secret=JBSWY3DPEHPK3PXP", "", "john@email.com"},
+		// issuer parameter containing colon
+		{"otpauth://totp/ACME%3ACo:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME%3ACo", "ACME:Co", "ACME:Co:john"},
+		// upper-case type
+		{"otpauth://TOTP/ACME:john?secret=JBSWY3DPEHPK3PXP", "ACME", "ACME:john"},
+	}
+
+	for _, tc := range tests {
+		z, err := Parse(tc.url)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.url, err)
+		}
+
+		if z.Issuer != tc.issuer {
+			t.Fatalf("%s: incorrect issuer: %s", tc.url, z.Issuer)
+		}
+
+		if z.Label != tc.label {
+			t.Fatalf("%s: incorrect label: %s", tc.url, z.Label)
+		}
+	}
+}
+
+func TestParseUrlInvalid(t *testing.T) {
+	tests := []string{
+		"https://totp/ACME:john?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://xotp/ACME:john?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/ACME:?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/ACME:john",
+		"otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PX1",
+		"otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&algorithm=md5",
+		"otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&digits=x",
+		"otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&period=0",
+		// counter is required for hotp
+		"otpauth://hotp/ACME:john?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://hotp/ACME:john?secret=JBSWY3DPEHPK3PXP&counter=-1",
+		// hotp only supports sha1
+		"otpauth://hotp/ACME:john?secret=JBSWY3DPEHPK3PXP&counter=1&algorithm=SHA256",
+	}
+
+	for _, u := range tests {
+		_, err := Parse(u)
+		if err == nil {
+			t.Fatal("expected test to fail for url: ", u)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []string{
+		"otpauth://totp/ACME%20Co:john.doe@email.com?secret=JBSWY3DPEHPK3PXP&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60",
+		"otpauth://hotp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME&algorithm=SHA1&digits=6&counter=42",
+		"otpauth://totp/john?secret=JBSWY3DPEHPK3PXP&algorithm=SHA1&digits=6&period=30",
+		// colon in issuer is escaped
+		"otpauth://totp/ACME%3ACo:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME%3ACo&algorithm=SHA1&digits=6&period=30",
+	}
+
+	for _, u := range tests {
+		z, err := Parse(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := Format(z)
+		if f != u {
+			t.Fatalf("unexpected output: %s. expected: %s", f, u)
+		}
+
+		z2, err := Parse(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fmt.Sprint(z2) != fmt.Sprint(z) {
+			t.Fatalf("round-trip mismatch: %v != %v", z2, z)
+		}
+	}
+}
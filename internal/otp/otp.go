@@ -1,6 +1,11 @@
 package otp
 
 import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
 	"strings"
 	"time"
 
@@ -8,21 +13,32 @@ import (
 	"github.com/grijul/zauth/internal/zauth"
 )
 
-// GenerateOTP generates TOTP/HOTP code and returns pointer to ZAuthOtp object and any errors encountered.
+// steamAlphabet is the alphabet of Steam Guard codes.
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+// GenerateOTP generates TOTP/HOTP/Steam code and returns pointer to ZAuthOtp object and any errors encountered.
 func GenerateOTP(z *zauth.ZAuth) (*zauth.ZAuthOtp, error) {
-	t := strings.ToUpper(z.Type)
-	if t == "TOTP" {
-		return generateTOTP(z)
+	return GenerateOTPAt(z, time.Now())
+}
+
+// GenerateOTPAt generates TOTP/HOTP/Steam code valid at time t and returns pointer to ZAuthOtp object and any errors encountered.
+func GenerateOTPAt(z *zauth.ZAuth, t time.Time) (*zauth.ZAuthOtp, error) {
+	typ := strings.ToUpper(z.Type)
+	if typ == "TOTP" {
+		return generateTOTPAt(z, t)
+
+	} else if typ == "STEAM" {
+		return generateSteamAt(z, t)
 
 	} else {
 		return generateHOTP(z)
 	}
 }
 
-// generateTOTP generates TOTP code and returns pointer to ZAuthOtp object and any errors encountered.
-func generateTOTP(z *zauth.ZAuth) (*zauth.ZAuthOtp, error) {
-	tm := time.Now().Unix()
-	t := &otpgen.TOTP{
+// generateTOTPAt generates TOTP code valid at time t.
+func generateTOTPAt(z *zauth.ZAuth, t time.Time) (*zauth.ZAuthOtp, error) {
+	tm := t.Unix()
+	g := &otpgen.TOTP{
 		Secret:    z.Secret,
 		Digits:    z.Digits,
 		Algorithm: z.Algorithm,
@@ -30,13 +46,55 @@ func generateTOTP(z *zauth.ZAuth) (*zauth.ZAuthOtp, error) {
 		UnixTime:  tm,
 	}
 
-	o, err := t.Generate()
+	o, err := g.Generate()
 	return &zauth.ZAuthOtp{
 		Otp:       o,
 		Remaining: z.Period - (tm % z.Period),
 	}, err
 }
 
+// generateSteamAt generates Steam Guard code valid at time t.
+// Steam Guard codes are TOTP codes (SHA1) whose truncated value is encoded with steamAlphabet instead of decimal digits.
+func generateSteamAt(z *zauth.ZAuth, t time.Time) (*zauth.ZAuthOtp, error) {
+	period := z.Period
+	if period <= 0 {
+		period = zauth.DefaultPeriod
+	}
+
+	sec := strings.ToUpper(strings.ReplaceAll(z.Secret, " ", ""))
+	if r := len(sec) % 8; r != 0 {
+		sec += strings.Repeat("=", 8-r)
+	}
+
+	key, err := base32.StdEncoding.DecodeString(sec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base32 secret")
+	}
+
+	tm := t.Unix()
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(tm/period))
+
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+
+	// dynamic truncation (RFC 4226)
+	o := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[o:o+4]) & 0x7fffffff
+
+	code := make([]byte, zauth.SteamDigits)
+	for i := range code {
+		code[i] = steamAlphabet[v%uint32(len(steamAlphabet))]
+		v /= uint32(len(steamAlphabet))
+	}
+
+	return &zauth.ZAuthOtp{
+		Otp:       string(code),
+		Remaining: period - (tm % period),
+	}, nil
+}
+
 // generateHOTP generates HOTP code and returns pointer to ZAuthOtp object and any errors encountered.
 func generateHOTP(z *zauth.ZAuth) (*zauth.ZAuthOtp, error) {
 	h := otpgen.HOTP{
@@ -51,3 +109,19 @@ func generateHOTP(z *zauth.ZAuth) (*zauth.ZAuthOtp, error) {
 		Remaining: 0,
 	}, err
 }
+
+// NextHOTP generates HOTP code for current counter of z and increments the counter.
+// Caller is responsible for persisting z, so that the same code is never generated twice.
+func NextHOTP(z *zauth.ZAuth) (*zauth.ZAuthOtp, error) {
+	if strings.ToUpper(z.Type) != "HOTP" {
+		return nil, fmt.Errorf("not a hotp entry: %s", z.Label)
+	}
+
+	o, err := generateHOTP(z)
+	if err != nil {
+		return nil, err
+	}
+
+	z.Counter++
+	return o, nil
+}
@@ -2,6 +2,7 @@ package otp
 
 import (
 	"testing"
+	"time"
 
 	"github.com/grijul/zauth/internal/oauthurl"
 )
@@ -37,7 +38,7 @@ func TestGenerateTOTP(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	zo, err := generateTOTP(z)
+	zo, err := generateTOTPAt(z, time.Now())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -65,3 +66,83 @@ func TestGenerateHOTP(t *testing.T) {
 	}
 
 }
+
+func TestNextHOTP(t *testing.T) {
+	hotpUrl := "otpauth://hotp/SomeOrg:test@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA1&digits=6&counter=5"
+	z, err := oauthurl.Parse(hotpUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zo, err := generateHOTP(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	no, err := NextHOTP(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if no.Otp != zo.Otp || z.Counter != 6 {
+		t.Fatalf("unexpected otp/counter: %s/%d", no.Otp, z.Counter)
+	}
+
+	no, err = NextHOTP(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if no.Otp == zo.Otp || z.Counter != 7 {
+		t.Fatalf("expected new otp for incremented counter: %s/%d", no.Otp, z.Counter)
+	}
+
+	totpUrl := "otpauth://totp/SomeOrg:test@example.com?secret=NBSWY3DPO5XXE3DEBI======&issuer=SomeOrg&algorithm=SHA1&digits=6&period=30"
+	z, err = oauthurl.Parse(totpUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NextHOTP(z)
+	if err == nil {
+		t.Fatal("expected test to fail for totp entry")
+	}
+}
+
+func TestGenerateSteam(t *testing.T) {
+	steamUrl := "otpauth://steam/Steam:test?secret=JBSWY3DPEHPK3PXP&issuer=Steam"
+	z, err := oauthurl.Parse(steamUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		t   int64
+		otp string
+	}{
+		{0, "VH8YJ"},
+		{59, "2YXGV"},
+		{1620140236, "GW7XF"},
+	}
+
+	for _, tc := range tests {
+		zo, err := GenerateOTPAt(z, time.Unix(tc.t, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if zo.Otp != tc.otp || zo.Remaining != 30-tc.t%30 {
+			t.Fatalf("expected %s (%ds). received: %s (%ds)", tc.otp, 30-tc.t%30, zo.Otp, zo.Remaining)
+		}
+	}
+
+	// andOTP type
+	z.Type = "STEAM"
+	zo, err := GenerateOTPAt(z, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zo.Otp != "VH8YJ" {
+		t.Fatalf("expected VH8YJ. received: %s", zo.Otp)
+	}
+}
@@ -0,0 +1,109 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+)
+
+// quietZone is the light border (in modules) around rendered QR codes.
+const quietZone = 2
+
+// Render encodes content s as QR code and returns it drawn with unicode block characters.
+// Two rows of modules are drawn per line of text. Light modules are drawn as blocks,
+// so that code is readable by scanners on terminals with dark background.
+func Render(s string) (string, error) {
+	hints := map[gozxing.EncodeHintType]interface{}{
+		gozxing.EncodeHintType_ERROR_CORRECTION: decoder.ErrorCorrectionLevel_M,
+		gozxing.EncodeHintType_MARGIN:           quietZone,
+	}
+
+	m, err := qrcode.NewQRCodeWriter().Encode(s, gozxing.BarcodeFormat_QR_CODE, 0, 0, hints)
+	if err != nil {
+		return "", err
+	}
+
+	w, h := m.GetWidth(), m.GetHeight()
+	light := func(x, y int) bool {
+		return y >= h || !m.Get(x, y)
+	}
+
+	var b strings.Builder
+	for y := 0; y < h; y += 2 {
+		for x := 0; x < w; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+// Decode decodes all QR codes found in image img and returns their contents.
+// Returns an error if no QR code is found.
+func Decode(img image.Image) ([]string, error) {
+	src := gozxing.NewLuminanceSourceFromImage(img)
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+
+	// try inverted image as well for light-on-dark codes (eg: screenshots in dark mode)
+	for _, s := range []gozxing.LuminanceSource{src, gozxing.NewInvertedLuminanceSource(src)} {
+		bmp, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(s))
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := multiqr.NewQRCodeMultiReader().DecodeMultiple(bmp, hints)
+		if err != nil || len(res) == 0 {
+			r, err := qrcode.NewQRCodeReader().Decode(bmp, hints)
+			if err != nil {
+				continue
+			}
+			res = []*gozxing.Result{r}
+		}
+
+		txt := make([]string, 0, len(res))
+		for _, r := range res {
+			txt = append(txt, r.GetText())
+		}
+		return txt, nil
+	}
+
+	return nil, fmt.Errorf("no QR code found")
+}
+
+// DecodeFile decodes all QR codes found in PNG/JPEG/GIF image file f and returns their contents.
+func DecodeFile(f string) ([]string, error) {
+	fl, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fl.Close()
+
+	img, _, err := image.Decode(fl)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(img)
+}
@@ -0,0 +1,125 @@
+package qr
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+func TestRender(t *testing.T) {
+	s, err := Render("otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) < 10 {
+		t.Fatal("unexpected line count: ", len(lines))
+	}
+
+	w := utf8.RuneCountInString(lines[0])
+	for _, l := range lines {
+		if utf8.RuneCountInString(l) != w {
+			t.Fatal("lines have different widths")
+		}
+	}
+
+	// quiet zone is drawn light
+	if strings.Trim(lines[0], "█") != "" {
+		t.Fatal("expected light quiet zone: ", lines[0])
+	}
+
+	_, err = Render("")
+	if err == nil {
+		t.Fatal("expected test to fail with empty content")
+	}
+}
+
+// testImage returns image containing QR codes of contents s placed side by side.
+func testImage(t *testing.T, s ...string) image.Image {
+	const size = 300
+	img := image.NewGray(image.Rect(0, 0, size*len(s), size))
+	for i, c := range s {
+		m, err := qrcode.NewQRCodeWriter().Encode(c, gozxing.BarcodeFormat_QR_CODE, size, size, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if m.Get(x, y) {
+					img.SetGray(i*size+x, y, color.Gray{0})
+				} else {
+					img.SetGray(i*size+x, y, color.Gray{255})
+				}
+			}
+		}
+	}
+	return img
+}
+
+func TestDecode(t *testing.T) {
+	a := "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME"
+	b := "otpauth://hotp/ACME:jane?secret=JBSWY3DPEHPK3PXP&issuer=ACME&counter=1"
+
+	txt, err := Decode(testImage(t, a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txt) != 1 || txt[0] != a {
+		t.Fatal("unexpected output: ", txt)
+	}
+
+	// several codes in one image
+	txt, err = Decode(testImage(t, a, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(txt)
+	if len(txt) != 2 || txt[0] != b || txt[1] != a {
+		t.Fatal("unexpected output: ", txt)
+	}
+
+	// no code
+	_, err = Decode(image.NewGray(image.Rect(0, 0, 100, 100)))
+	if err == nil {
+		t.Fatal("expected test to fail when image has no QR code")
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	a := "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME"
+	f := filepath.Join(t.TempDir(), "qr.png")
+
+	fl, err := os.Create(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(fl, testImage(t, a))
+	fl.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txt, err := DecodeFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txt) != 1 || txt[0] != a {
+		t.Fatal("unexpected output: ", txt)
+	}
+
+	_, err = DecodeFile(filepath.Join(t.TempDir(), "missing.png"))
+	if err == nil {
+		t.Fatal("expected test to fail when file does not exist")
+	}
+}
@@ -0,0 +1,258 @@
+package watch
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/otp"
+	"github.com/grijul/zauth/internal/zauth"
+	"github.com/mattn/go-runewidth"
+)
+
+// KeyCode identifies a key read from terminal.
+type KeyCode int
+
+const (
+	KeyRune      KeyCode = iota // printable character (see Key.Rune)
+	KeyEnter                    // Enter
+	KeyBackspace                // Backspace
+	KeyUp                       // Up arrow or Ctrl+P
+	KeyDown                     // Down arrow or Ctrl+N
+	KeyEsc                      // Escape
+	KeyQuit                     // Ctrl+C or Ctrl+D
+)
+
+// Key is a key read from terminal.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// Action is an action requested by a key, to be performed by caller of HandleKey.
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionCopy        // copy code of selected entry (generating next code of HOTP entry)
+	ActionQuit        // exit watch mode
+)
+
+// barWidth is the width of countdown bars (in characters).
+const barWidth = 10
+
+// headerLines is the number of lines drawn before entries.
+const headerLines = 4
+
+// Model is the state of watch mode. It is independent of terminal, so that it can be tested.
+type Model struct {
+	Entries  []zauth.ZAuth
+	Query    string // incremental search query
+	Selected int    // index of selected entry in visible entries
+	Status   string // status message (eg: copied to clipboard)
+
+	hotp map[string]string // last generated HOTP code by entry ID
+}
+
+// NewModel returns Model showing entries z.
+func NewModel(z []zauth.ZAuth) *Model {
+	return &Model{Entries: z, hotp: make(map[string]string)}
+}
+
+// Visible returns indexes of entries matching search query.
+func (m *Model) Visible() []int {
+	return common.FilterEntries(m.Entries, m.Query)
+}
+
+// SelectedEntry returns selected entry, or nil if no entry is visible.
+func (m *Model) SelectedEntry() *zauth.ZAuth {
+	v := m.Visible()
+	if len(v) == 0 {
+		return nil
+	}
+
+	if m.Selected >= len(v) {
+		m.Selected = len(v) - 1
+	}
+	return &m.Entries[v[m.Selected]]
+}
+
+// SetHOTP sets code c generated for HOTP entry with ID id, to be shown until next code is generated.
+func (m *Model) SetHOTP(id string, c string) {
+	m.hotp[id] = c
+}
+
+// HandleKey updates model for key k and returns action to be performed.
+func (m *Model) HandleKey(k Key) Action {
+	switch k.Code {
+	case KeyRune:
+		m.Query += string(k.Rune)
+		m.Selected = 0
+
+	case KeyBackspace:
+		if r := []rune(m.Query); len(r) > 0 {
+			m.Query = string(r[:len(r)-1])
+			m.Selected = 0
+		}
+
+	case KeyUp:
+		if m.Selected > 0 {
+			m.Selected--
+		}
+
+	case KeyDown:
+		if m.Selected < len(m.Visible())-1 {
+			m.Selected++
+		}
+
+	case KeyEnter:
+		if m.SelectedEntry() != nil {
+			return ActionCopy
+		}
+
+	case KeyEsc:
+		// first Esc clears search
+		if m.Query != "" {
+			m.Query = ""
+			m.Selected = 0
+		} else {
+			return ActionQuit
+		}
+
+	case KeyQuit:
+		return ActionQuit
+	}
+
+	return ActionNone
+}
+
+// Render draws model at time now for terminal of size width x height.
+// Lines are separated by \n.
+func (m *Model) Render(now time.Time, width int, height int) string {
+	lines := []string{
+		"zauth watch (type to search, Up/Down select, Enter copy/generate HOTP, Esc quit)",
+		"Search: " + m.Query + "_",
+		"",
+	}
+
+	v := m.Visible()
+	if len(v) == 0 {
+		lines = append(lines, "no entries match search")
+	}
+
+	iw, lw := 0, 0
+	for _, i := range v {
+		iw = max(iw, runewidth.StringWidth(m.Entries[i].Issuer))
+		lw = max(lw, runewidth.StringWidth(common.LabelIdentifier(m.Entries[i].Label)))
+	}
+
+	// scroll so that selected entry is visible
+	rows := height - headerLines
+	if rows < 1 {
+		rows = 1
+	}
+	m.SelectedEntry()
+	off := 0
+	if m.Selected >= rows {
+		off = m.Selected - rows + 1
+	}
+
+	for n := off; n < len(v) && n < off+rows; n++ {
+		z := &m.Entries[v[n]]
+
+		cur := "  "
+		if n == m.Selected {
+			cur = "> "
+		}
+
+		code, info := m.code(z, now)
+		l := fmt.Sprintf("%s%s  %s  %s  %s", cur, runewidth.FillRight(z.Issuer, iw), runewidth.FillRight(common.LabelIdentifier(z.Label), lw), code, info)
+		lines = append(lines, runewidth.Truncate(l, width, ""))
+	}
+
+	lines = append(lines, "", m.Status)
+	return strings.Join(lines, "\n")
+}
+
+// code returns code of entry z at time now and countdown bar (TOTP) or hint (HOTP).
+func (m *Model) code(z *zauth.ZAuth, now time.Time) (string, string) {
+	if strings.ToLower(z.Type) == "hotp" {
+		if c, ok := m.hotp[z.ID]; ok {
+			return c, "(HOTP, Enter for next code)"
+		}
+		return strings.Repeat("-", z.Digits), "(HOTP, press Enter to generate)"
+	}
+
+	o, err := otp.GenerateOTPAt(z, now)
+	if err != nil {
+		return "error", err.Error()
+	}
+
+	return o.Otp, Bar(o.Remaining, z.Period)
+}
+
+// Bar returns countdown bar for remaining seconds r of period p, followed by remaining seconds.
+func Bar(r int64, p int64) string {
+	f := 0
+	if p > 0 {
+		f = int((r*barWidth + p - 1) / p)
+	}
+	if f > barWidth {
+		f = barWidth
+	}
+	return fmt.Sprintf("[%s%s] %2ds", strings.Repeat("#", f), strings.Repeat(".", barWidth-f), r)
+}
+
+// ParseKeys parses keys from terminal input b (read in raw mode).
+func ParseKeys(b []byte) []Key {
+	keys := make([]Key, 0)
+	s := string(b)
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "\x1b[A") || strings.HasPrefix(s, "\x1bOA"):
+			keys = append(keys, Key{Code: KeyUp})
+			s = s[3:]
+		case strings.HasPrefix(s, "\x1b[B") || strings.HasPrefix(s, "\x1bOB"):
+			keys = append(keys, Key{Code: KeyDown})
+			s = s[3:]
+		case strings.HasPrefix(s, "\x1b[") || strings.HasPrefix(s, "\x1bO"):
+			// ignore other escape sequences (eg: function keys)
+			i := strings.IndexFunc(s[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+			if i < 0 {
+				return keys
+			}
+			s = s[i+3:]
+		default:
+			r := []rune(s)[0]
+			s = s[len(string(r)):]
+			switch r {
+			case '\x1b':
+				keys = append(keys, Key{Code: KeyEsc})
+			case '\r', '\n':
+				keys = append(keys, Key{Code: KeyEnter})
+			case '\x7f', '\b':
+				keys = append(keys, Key{Code: KeyBackspace})
+			case '\x10':
+				keys = append(keys, Key{Code: KeyUp})
+			case '\x0e':
+				keys = append(keys, Key{Code: KeyDown})
+			case '\x03', '\x04':
+				keys = append(keys, Key{Code: KeyQuit})
+			default:
+				if unicode.IsPrint(r) {
+					keys = append(keys, Key{Code: KeyRune, Rune: r})
+				}
+			}
+		}
+	}
+	return keys
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
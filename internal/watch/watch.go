@@ -0,0 +1,207 @@
+package watch
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grijul/zauth/internal/clipboard"
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/otp"
+	"github.com/grijul/zauth/internal/zauth"
+	"golang.org/x/term"
+)
+
+// refreshInterval is the interval between redraws. It is shorter than a second, so that codes are updated as soon as periods roll over.
+const refreshInterval = 250 * time.Millisecond
+
+// Run shows entries of store st full-screen until user quits, redrawing codes as periods roll over.
+// If tag is not empty, only entries having tag are shown.
+// Codes are copied to clipboard cb and cleared after timeout clear (or when TOTP period rolls over).
+// Terminal is restored on exit.
+func Run(st *common.Store, tag string, cb clipboard.Clipboard, clear time.Duration) error {
+	in := int(os.Stdin.Fd())
+	out := int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return fmt.Errorf("watch requires a terminal")
+	}
+
+	zl, err := st.ReadZAuthJson()
+	if err != nil {
+		return err
+	}
+	idx := make([]int, len(zl))
+	for i := range zl {
+		idx[i] = i
+	}
+
+	ent := make([]zauth.ZAuth, 0, len(zl))
+	for _, i := range common.FilterTag(zl, idx, tag) {
+		ent = append(ent, zl[i])
+	}
+	m := NewModel(ent)
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+
+	// alternate screen, hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+
+	// terminal is restored before a panic is reported, so that it is not left in raw mode on the alternate screen
+	defer func() {
+		r := recover()
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		term.Restore(in, state)
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	var clearAt time.Time
+	defer func() {
+		if !clearAt.IsZero() {
+			cb.Clear()
+		}
+	}()
+
+	keys := make(chan []byte)
+	go func() {
+		b := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(b)
+			if err != nil {
+				close(keys)
+				return
+			}
+			k := make([]byte, n)
+			copy(k, b[:n])
+			keys <- k
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sig)
+
+	tick := time.NewTicker(refreshInterval)
+	defer tick.Stop()
+
+	for {
+		w, h, err := term.GetSize(out)
+		if err != nil {
+			w, h = 80, 24
+		}
+
+		// redraw in place, clearing rest of each line and screen
+		r := strings.ReplaceAll(m.Render(time.Now(), w, h), "\n", "\x1b[K\r\n")
+		fmt.Print("\x1b[H" + r + "\x1b[K\x1b[J")
+
+		select {
+		case b, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			for _, k := range ParseKeys(b) {
+				switch m.HandleKey(k) {
+				case ActionQuit:
+					return nil
+
+				case ActionCopy:
+					z := m.SelectedEntry()
+					o, err := code(st, z)
+					if err != nil {
+						m.Status = fmt.Sprintf("An error occured while generating OTP: %v", err)
+						break
+					}
+
+					if strings.ToLower(z.Type) == "hotp" {
+						m.SetHOTP(z.ID, o.Otp)
+					}
+
+					err = cb.Copy(o.Otp)
+					if err != nil {
+						m.Status = fmt.Sprintf("An error occured while copying OTP: %v", err)
+						break
+					}
+
+					m.Status = fmt.Sprintf("OTP of (%s) %s copied to clipboard (%s)", z.Issuer, z.Label, cb.Name())
+					if clear > 0 {
+						d := clear
+						if r := time.Duration(o.Remaining) * time.Second; r > 0 && r < d {
+							d = r
+						}
+						clearAt = time.Now().Add(d)
+					}
+				}
+			}
+
+		case <-tick.C:
+			if !clearAt.IsZero() && time.Now().After(clearAt) {
+				clearAt = time.Time{}
+				err = cb.Clear()
+				if err != nil {
+					m.Status = fmt.Sprintf("An error occured while clearing clipboard: %v", err)
+				} else {
+					m.Status = "clipboard cleared"
+				}
+			}
+
+		case <-sig:
+			return nil
+		}
+	}
+}
+
+// code returns current code of entry z and records it's use (see common.RecordUsage) in store st.
+// For HOTP entries, next code is generated and incremented counter is saved as well.
+func code(st *common.Store, z *zauth.ZAuth) (*zauth.ZAuthOtp, error) {
+	err := st.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer st.Unlock()
+
+	// entries are read again, as they may have been changed by another process
+	v, err := st.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range v.Entries {
+		e := &v.Entries[i]
+		if e.ID != z.ID {
+			continue
+		}
+
+		// only HOTP counter changes replace the backup of zauth.json (see common.Store.WriteUsage)
+		var o *zauth.ZAuthOtp
+		write := st.WriteUsage
+		if strings.ToLower(e.Type) != "hotp" {
+			o, err = otp.GenerateOTP(e)
+		} else {
+			o, err = otp.NextHOTP(e)
+			write = st.Write
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		common.RecordUsage(e, time.Now())
+		err = write(v)
+		if err != nil {
+			return nil, err
+		}
+
+		z.Counter = e.Counter
+		z.Misc = e.Misc
+		return o, nil
+	}
+
+	return nil, fmt.Errorf("entry not found: %s", z.Label)
+}
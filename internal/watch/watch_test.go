@@ -0,0 +1,123 @@
+package watch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+func testEntries() []zauth.ZAuth {
+	return []zauth.ZAuth{
+		{ID: "1", Secret: "JBSWY3DPEHPK3PXP", Issuer: "GitHub", Label: "GitHub:alice", Digits: 6, Algorithm: "sha1", Period: 30, Type: "totp"},
+		{ID: "2", Secret: "JBSWY3DPEHPK3PXP", Issuer: "Google", Label: "Google:bob", Digits: 6, Algorithm: "sha1", Period: 30, Type: "totp"},
+		{ID: "3", Secret: "JBSWY3DPEHPK3PXP", Issuer: "Bank", Label: "Bank:carol", Digits: 8, Counter: 1, Type: "hotp"},
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := ParseKeys([]byte("a\x1b[A\x1b[B\r\x7f\x1b\x03\x1b[15~é"))
+	exp := []Key{
+		{Code: KeyRune, Rune: 'a'},
+		{Code: KeyUp},
+		{Code: KeyDown},
+		{Code: KeyEnter},
+		{Code: KeyBackspace},
+		{Code: KeyEsc},
+		{Code: KeyQuit},
+		{Code: KeyRune, Rune: 'é'},
+	}
+
+	if fmt.Sprint(keys) != fmt.Sprint(exp) {
+		t.Fatalf("expected %v. received: %v", exp, keys)
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	m := NewModel(testEntries())
+
+	// incremental search
+	for _, r := range "go" {
+		m.HandleKey(Key{Code: KeyRune, Rune: r})
+	}
+	if fmt.Sprint(m.Visible()) != "[1]" {
+		t.Fatal("unexpected visible entries: ", m.Visible())
+	}
+
+	m.HandleKey(Key{Code: KeyBackspace})
+	if fmt.Sprint(m.Visible()) != "[0 1]" {
+		t.Fatal("unexpected visible entries: ", m.Visible())
+	}
+
+	// selection stays within visible entries
+	m.HandleKey(Key{Code: KeyDown})
+	m.HandleKey(Key{Code: KeyDown})
+	if m.SelectedEntry().Issuer != "Google" {
+		t.Fatal("unexpected selected entry: ", m.SelectedEntry())
+	}
+
+	if m.HandleKey(Key{Code: KeyEnter}) != ActionCopy {
+		t.Fatal("expected copy action")
+	}
+
+	// first Esc clears search, second quits
+	if m.HandleKey(Key{Code: KeyEsc}) != ActionNone || m.Query != "" {
+		t.Fatal("expected search to be cleared")
+	}
+	if m.HandleKey(Key{Code: KeyEsc}) != ActionQuit {
+		t.Fatal("expected quit action")
+	}
+
+	// nothing to copy
+	m.HandleKey(Key{Code: KeyRune, Rune: 'x'})
+	if m.HandleKey(Key{Code: KeyEnter}) != ActionNone {
+		t.Fatal("expected no action when no entry is visible")
+	}
+}
+
+func TestRender(t *testing.T) {
+	m := NewModel(testEntries())
+	now := time.Unix(59, 0)
+
+	r := m.Render(now, 120, 24)
+	if !strings.Contains(r, "> GitHub") || !strings.Contains(r, "[#.........]  1s") {
+		t.Fatalf("unexpected render:\n%s", r)
+	}
+
+	if !strings.Contains(r, "--------  (HOTP, press Enter to generate)") {
+		t.Fatalf("expected HOTP code to be hidden:\n%s", r)
+	}
+
+	m.SetHOTP("3", "12345678")
+	r = m.Render(now, 120, 24)
+	if !strings.Contains(r, "12345678") {
+		t.Fatalf("expected generated HOTP code:\n%s", r)
+	}
+
+	// selected entry is visible on small terminal
+	m.Selected = 2
+	r = m.Render(now, 120, headerLines+1)
+	if !strings.Contains(r, "> Bank") || strings.Contains(r, "GitHub") {
+		t.Fatalf("unexpected render:\n%s", r)
+	}
+}
+
+func TestBar(t *testing.T) {
+	tests := []struct {
+		r   int64
+		p   int64
+		bar string
+	}{
+		{30, 30, "[##########] 30s"},
+		{15, 30, "[#####.....] 15s"},
+		{1, 30, "[#.........]  1s"},
+	}
+
+	for _, tc := range tests {
+		if b := Bar(tc.r, tc.p); b != tc.bar {
+			t.Fatalf("expected %q. received: %q", tc.bar, b)
+		}
+	}
+}
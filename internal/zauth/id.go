@@ -0,0 +1,28 @@
+package zauth
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// ShortIDLen is the length of entry ID prefix shown in listings.
+const ShortIDLen = 8
+
+// NewID returns a new random (version 4) UUID to be used as entry ID.
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// ShortID returns prefix of entry ID id shown in listings.
+func ShortID(id string) string {
+	if len(id) > ShortIDLen {
+		return id[:ShortIDLen]
+	}
+	return id
+}
@@ -1,12 +1,7 @@
 package zauth
 
-import (
-	"os"
-	"path/filepath"
-	"runtime"
-)
-
 type ZAuth struct {
+	ID        string                 `json:"id"` // stable unique ID (UUID), assigned when entry is created or imported
 	Secret    string                 `json:"secret"`
 	Label     string                 `json:"label"`
 	Issuer    string                 `json:"issuer"`
@@ -15,7 +10,32 @@ type ZAuth struct {
 	Counter   int64                  `json:"counter"`
 	Period    int64                  `json:"period"`
 	Type      string                 `json:"type"`
-	Misc      map[string]interface{} `json:"misc"` // to store all other nodes (that are not part of ZAuth at time of import)
+	Tags      []string               `json:"tags"`  // tags (andOTP tags, Aegis groups), used to filter listings
+	Notes     string                 `json:"notes"` // free text (eg: where recovery codes are kept)
+	URL       string                 `json:"url"`   // login URL
+	Meta      map[string]string      `json:"meta"`  // custom metadata (eg: owner of a shared account)
+	Misc      map[string]interface{} `json:"misc"`  // to store all other nodes (that are not part of ZAuth at time of import)
+}
+
+// ZAuthDeleted is an entry moved to trash by entry -delete.
+type ZAuthDeleted struct {
+	ZAuth
+	Deleted int64 `json:"deleted"` // deletion time (unix)
+}
+
+// ZAuthVault is the content of zauth.json.
+type ZAuthVault struct {
+	Version int            `json:"version"` // schema version (see common.SchemaVersion)
+	Meta    ZAuthMeta      `json:"meta"`
+	Entries []ZAuth        `json:"entries"`
+	Trash   []ZAuthDeleted `json:"trash"`
+}
+
+// ZAuthMeta is the metadata of zauth.json.
+type ZAuthMeta struct {
+	Name     string `json:"name"`     // vault name
+	Created  int64  `json:"created"`  // creation time (unix)
+	Modified int64  `json:"modified"` // last modification time (unix)
 }
 
 type ZAuthOtp struct {
@@ -29,12 +49,5 @@ const DefaultCounter = 0
 const DefaultAlgo = "sha1"
 const DefaultPeriod = 30
 
-var ZAuthJsonDir string = filepath.Join(homeDir(), ".zauth")
-var ZAuthJson string = filepath.Join(ZAuthJsonDir, "zauth.json")
-
-func homeDir() string {
-	if runtime.GOOS == "windows" {
-		return os.Getenv("USERPROFILE")
-	}
-	return os.Getenv("HOME")
-}
+// SteamDigits is the length of Steam Guard codes.
+const SteamDigits = 5
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"os"
+
 	"github.com/grijul/zauth/internal/args"
 	"github.com/grijul/zauth/internal/common"
 )
 
 func main() {
 	zc := &common.ZAuthCommon{}
-	args.ParseArgs(zc)
+	err := args.ParseArgs(zc)
+	if err != nil {
+		os.Exit(1)
+	}
 }
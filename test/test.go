@@ -10,9 +10,13 @@ import (
 var TestDir = getTestDir()
 var TestAndotpAccountsJson = filepath.Join(TestDir, "andotp_accounts.json")
 var TestAndotpAccountsJsonEnc = filepath.Join(TestDir, "andotp_accounts.json.enc")
+var TestAegisPlainJson = filepath.Join(TestDir, "aegis_plain.json")
+var TestAegisEncryptedJson = filepath.Join(TestDir, "aegis_encrypted.json")
+var TestQrAccountsPng = filepath.Join(TestDir, "qr_accounts.png")
 var TestZAuthJsonDir = filepath.Join(os.TempDir(), "zauth")
 var TestZAuthJson = filepath.Join(TestZAuthJsonDir, "zauth.json")
 var AndOtpAccountsEncPassword = "testpass"
+var AegisEncryptedPassword = "testpass"
 
 func getTestDir() string {
 	_, fn, _, ok := runtime.Caller(0)
@@ -27,3 +31,12 @@ func getTestDir() string {
 func RemoveTestFiles() {
 	os.RemoveAll(TestZAuthJsonDir)
 }
+
+var TestVaultPassword = "vaultpass"
+
+// VaultPasswordReader is a PasswordReader returning TestVaultPassword.
+type VaultPasswordReader struct{}
+
+func (*VaultPasswordReader) ReadPassword() (string, error) {
+	return TestVaultPassword, nil
+}
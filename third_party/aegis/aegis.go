@@ -0,0 +1,515 @@
+package aegis
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/zauth"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Aegis vault format: https://github.com/beemdevelopment/Aegis/blob/master/docs/vault.md
+// Plain vaults contain db as a json object. Encrypted vaults contain db as base64 encoded AES-256-GCM ciphertext.
+// Database is encrypted with a random master key, which is stored in header slots encrypted with scrypt-derived keys.
+
+const vaultVersion = 1
+const dbVersion = 3
+const slotTypePassword = 1
+
+// scrypt parameters used by Aegis for password slots
+const scryptN = 32768
+const scryptR = 8
+const scryptP = 1
+
+// Maximum scrypt parameters accepted from imported vaults, so that a crafted file cannot exhaust memory or CPU.
+// Key derivation uses 128*N*R bytes of memory (256MB at most).
+const maxScryptNR = 1 << 21
+const maxScryptP = 16
+
+type aegisVault struct {
+	Version int             `json:"version"`
+	Header  aegisHeader     `json:"header"`
+	Db      json.RawMessage `json:"db"`
+}
+
+type aegisHeader struct {
+	Slots  []aegisSlot  `json:"slots"`
+	Params *aegisParams `json:"params"`
+}
+
+type aegisParams struct {
+	Nonce string `json:"nonce"`
+	Tag   string `json:"tag"`
+}
+
+type aegisSlot struct {
+	Type      int          `json:"type"`
+	Uuid      string       `json:"uuid"`
+	Key       string       `json:"key"`
+	KeyParams *aegisParams `json:"key_params"`
+	N         int          `json:"n,omitempty"`
+	R         int          `json:"r,omitempty"`
+	P         int          `json:"p,omitempty"`
+	Salt      string       `json:"salt,omitempty"`
+	Repaired  bool         `json:"repaired,omitempty"`
+	IsBackup  bool         `json:"is_backup,omitempty"`
+}
+
+type aegisDb struct {
+	Version int          `json:"version"`
+	Entries []aegisEntry `json:"entries"`
+	Groups  []aegisGroup `json:"groups,omitempty"`
+}
+
+type aegisGroup struct {
+	Uuid string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+type aegisEntry struct {
+	Type     string    `json:"type"`
+	Uuid     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Issuer   string    `json:"issuer"`
+	Note     string    `json:"note"`
+	Favorite bool      `json:"favorite"`
+	Icon     *string   `json:"icon"`
+	IconMime *string   `json:"icon_mime,omitempty"`
+	IconHash *string   `json:"icon_hash,omitempty"`
+	Info     aegisInfo `json:"info"`
+	Group    *string   `json:"group,omitempty"`  // db version 1 and 2
+	Groups   []string  `json:"groups,omitempty"` // db version 3 (group uuids)
+}
+
+type aegisInfo struct {
+	Secret  string `json:"secret"`
+	Algo    string `json:"algo"`
+	Digits  int    `json:"digits"`
+	Period  int64  `json:"period,omitempty"`
+	Counter *int64 `json:"counter,omitempty"`
+}
+
+type AegisImportExport struct {
+	store *common.Store
+}
+
+// NewAegis returns Aegis importer/exporter reading and writing entries to store s.
+func NewAegis(s *common.Store) AegisImportExport {
+	return AegisImportExport{store: s}
+}
+
+// Import imports Aegis plain/encrypted vault f and returns ZAuth object and any errors encountered.
+// Password pwd is used for decrypting vault.
+// If ow is true, existing zauth.json file is overwritten. Else entries are appended.
+func (a AegisImportExport) Import(f string, pwd string, ow bool) ([]zauth.ZAuth, error) {
+	fc, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	v := aegisVault{}
+	err = json.Unmarshal(fc, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported aegis vault version: %d", v.Version)
+	}
+
+	db, err := decodeDb(&v, pwd)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]string)
+	for _, g := range db.Groups {
+		groups[g.Uuid] = g.Name
+	}
+
+	zl := make([]zauth.ZAuth, 0, len(db.Entries))
+	for _, e := range db.Entries {
+		t := strings.ToLower(e.Type)
+		if t != "totp" && t != "hotp" && t != "steam" {
+			fmt.Fprintf(os.Stderr, "skipping entry %s (%s): unsupported type %s\n", e.Issuer, e.Name, e.Type)
+			continue
+		}
+
+		// HOTP codes are only generated with SHA1
+		if t == "hotp" && e.Info.Algo != "" && !strings.EqualFold(e.Info.Algo, zauth.DefaultAlgo) {
+			fmt.Fprintf(os.Stderr, "skipping entry %s (%s): unsupported HOTP algorithm %s\n", e.Issuer, e.Name, e.Info.Algo)
+			continue
+		}
+
+		sec, err := parseSecret(e.Info.Secret)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping entry %s (%s): %v\n", e.Issuer, e.Name, err)
+			continue
+		}
+
+		z := zauth.ZAuth{
+			Secret:    sec,
+			Issuer:    e.Issuer,
+			Label:     e.Name,
+			Digits:    e.Info.Digits,
+			Algorithm: e.Info.Algo,
+			Period:    e.Info.Period,
+			Type:      t,
+			Misc:      make(map[string]interface{}),
+		}
+
+		if e.Issuer != "" && !strings.HasPrefix(e.Name, e.Issuer+":") {
+			z.Label = fmt.Sprintf("%s:%s", e.Issuer, e.Name)
+		}
+
+		if e.Info.Counter != nil {
+			z.Counter = *e.Info.Counter
+		}
+
+		// period is optional in Aegis vaults
+		if t != "hotp" && z.Period <= 0 {
+			z.Period = zauth.DefaultPeriod
+		}
+
+		z.Misc["uuid"] = e.Uuid
+		z.Notes = e.Note
+		z.Misc["favorite"] = e.Favorite
+		if e.Icon != nil {
+			z.Misc["icon"] = *e.Icon
+		}
+		if e.IconMime != nil {
+			z.Misc["icon_mime"] = *e.IconMime
+		}
+		if e.IconHash != nil {
+			z.Misc["icon_hash"] = *e.IconHash
+		}
+
+		grp := make([]string, 0)
+		if e.Group != nil && *e.Group != "" {
+			grp = append(grp, *e.Group)
+		}
+		for _, g := range e.Groups {
+			if n, ok := groups[g]; ok {
+				grp = append(grp, n)
+			}
+		}
+		z.Tags = common.NormalizeTags(grp)
+
+		zl = append(zl, z)
+	}
+
+	err = a.store.WriteZAuthJson(zl, ow)
+	if err != nil {
+		return nil, err
+	}
+
+	return zl, nil
+}
+
+// Export exports zauth's entries to Aegis-compatible vault file.
+// Output file is encrypted with password pwd if provided.
+func (a AegisImportExport) Export(pwd string) (*string, error) {
+	zj, err := a.store.ReadZAuthJson()
+	if err != nil {
+		return nil, err
+	}
+
+	db := aegisDb{
+		Version: dbVersion,
+		Entries: make([]aegisEntry, 0, len(zj)),
+		Groups:  make([]aegisGroup, 0),
+	}
+	groups := make(map[string]string)
+
+	for _, z := range zj {
+		e := aegisEntry{
+			Type:   strings.ToLower(z.Type),
+			Uuid:   defaultString(miscString(z.Misc, "uuid"), z.ID),
+			Name:   common.LabelIdentifier(z.Label),
+			Issuer: z.Issuer,
+			Note:   z.Notes,
+			Info: aegisInfo{
+				Secret: strings.TrimRight(z.Secret, "="),
+				Algo:   strings.ToUpper(z.Algorithm),
+				Digits: z.Digits,
+			},
+			Groups: make([]string, 0),
+		}
+
+		if e.Uuid == "" {
+			e.Uuid, err = zauth.NewID()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if e.Info.Algo == "" {
+			e.Info.Algo = strings.ToUpper(zauth.DefaultAlgo)
+		}
+
+		if e.Type == "hotp" {
+			c := z.Counter
+			e.Info.Counter = &c
+		} else {
+			e.Info.Period = z.Period
+		}
+
+		if f, ok := z.Misc["favorite"].(bool); ok {
+			e.Favorite = f
+		}
+
+		for _, k := range []string{"icon", "icon_mime", "icon_hash"} {
+			if s := miscString(z.Misc, k); s != "" {
+				switch k {
+				case "icon":
+					e.Icon = &s
+				case "icon_mime":
+					e.IconMime = &s
+				case "icon_hash":
+					e.IconHash = &s
+				}
+			}
+		}
+
+		for _, g := range common.NormalizeTags(z.Tags) {
+			if _, ok := groups[g]; !ok {
+				groups[g], err = zauth.NewID()
+				if err != nil {
+					return nil, err
+				}
+				db.Groups = append(db.Groups, aegisGroup{Uuid: groups[g], Name: g})
+			}
+			e.Groups = append(e.Groups, groups[g])
+		}
+
+		db.Entries = append(db.Entries, e)
+	}
+
+	sort.Slice(db.Groups, func(i, j int) bool { return db.Groups[i].Name < db.Groups[j].Name })
+
+	d, err := encodeVault(&db, pwd)
+	if err != nil {
+		return nil, err
+	}
+
+	fl, err := common.WriteFile(d, a.store.Dir, common.GetFileName("aegis", pwd != ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fl, nil
+}
+
+// decodeDb returns database of vault v. Encrypted database is decrypted with password pwd.
+func decodeDb(v *aegisVault, pwd string) (*aegisDb, error) {
+	db := &aegisDb{}
+	if v.Header.Params == nil {
+		err := json.Unmarshal(v.Db, db)
+		return db, err
+	}
+
+	if pwd == "" {
+		return nil, fmt.Errorf("vault is encrypted. please provide password")
+	}
+
+	var enc string
+	err := json.Unmarshal(v.Db, &enc)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range v.Header.Slots {
+		if s.Type != slotTypePassword || s.KeyParams == nil {
+			continue
+		}
+
+		salt, err := hex.DecodeString(s.Salt)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.N <= 1 || s.N&(s.N-1) != 0 || s.R <= 0 || s.P <= 0 || s.N > maxScryptNR/s.R || s.P > maxScryptP {
+			return nil, fmt.Errorf("unsupported scrypt parameters: n=%d, r=%d, p=%d", s.N, s.R, s.P)
+		}
+
+		key, err := scrypt.Key([]byte(pwd), salt, s.N, s.R, s.P, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		ek, err := hex.DecodeString(s.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		mk, err := open(key, s.KeyParams, ek)
+		if err != nil {
+			// password does not match this slot
+			continue
+		}
+
+		pt, err := open(mk, v.Header.Params, ct)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(pt, db)
+		return db, err
+	}
+
+	return nil, fmt.Errorf("unable to decrypt vault: incorrect password")
+}
+
+// encodeVault returns vault file content for database db. Database is encrypted with password pwd if provided.
+func encodeVault(db *aegisDb, pwd string) ([]byte, error) {
+	b, err := json.Marshal(db)
+	if err != nil {
+		return nil, err
+	}
+
+	v := aegisVault{Version: vaultVersion, Header: aegisHeader{Slots: nil, Params: nil}}
+	if pwd == "" {
+		v.Db = b
+		return json.MarshalIndent(v, "", "    ")
+	}
+
+	mk := make([]byte, 32)
+	salt := make([]byte, 32)
+	for _, r := range [][]byte{mk, salt} {
+		if _, err := rand.Read(r); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := scrypt.Key([]byte(pwd), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	ek, kp, err := seal(key, mk)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, dp, err := seal(mk, b)
+	if err != nil {
+		return nil, err
+	}
+
+	uuid, err := zauth.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	v.Header.Slots = []aegisSlot{{
+		Type:      slotTypePassword,
+		Uuid:      uuid,
+		Key:       hex.EncodeToString(ek),
+		KeyParams: kp,
+		N:         scryptN,
+		R:         scryptR,
+		P:         scryptP,
+		Salt:      hex.EncodeToString(salt),
+		Repaired:  true,
+	}}
+	v.Header.Params = dp
+
+	v.Db, err = json.Marshal(base64.StdEncoding.EncodeToString(ct))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(v, "", "    ")
+}
+
+// open decrypts AES-256-GCM ciphertext ct with key k. Nonce and tag are read from p.
+func open(k []byte, p *aegisParams, ct []byte) ([]byte, error) {
+	nonce, err := hex.DecodeString(p.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := hex.DecodeString(p.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+
+	return gcm.Open(nil, nonce, append(append([]byte(nil), ct...), tag...), nil)
+}
+
+// seal encrypts pt with AES-256-GCM key k. Returns ciphertext (without tag) and nonce/tag params.
+func seal(k []byte, pt []byte) ([]byte, *aegisParams, error) {
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	ct := gcm.Seal(nil, nonce, pt, nil)
+	tl := len(ct) - gcm.Overhead()
+	return ct[:tl], &aegisParams{Nonce: hex.EncodeToString(nonce), Tag: hex.EncodeToString(ct[tl:])}, nil
+}
+
+func newGCM(k []byte) (cipher.AEAD, error) {
+	blk, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(blk)
+}
+
+// parseSecret returns base32 secret s upper-cased and padded, as Aegis stores secrets without padding.
+func parseSecret(s string) (string, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if r := len(s) % 8; r != 0 {
+		s += strings.Repeat("=", 8-r)
+	}
+
+	_, err := base32.StdEncoding.DecodeString(s)
+	if s == "" || err != nil {
+		return "", fmt.Errorf("invalid base32 secret")
+	}
+	return s, nil
+}
+
+func defaultString(v string, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func miscString(m map[string]interface{}, k string) string {
+	if s, ok := m[k].(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package aegis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/otp"
+	"github.com/grijul/zauth/internal/zauth"
+	"github.com/grijul/zauth/test"
+)
+
+func TestImport(t *testing.T) {
+	a := NewAegis(common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}))
+	test.RemoveTestFiles()
+	defer test.RemoveTestFiles()
+
+	// check for unencrypted file
+	zl, err := a.Import(test.TestAegisPlainJson, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HOTP entry using SHA512 is skipped, as HOTP codes are only generated with SHA1
+	if len(zl) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(zl))
+	}
+
+	if s := zl[2]; s.Label != "Steam:Sophia" || s.Type != "steam" || s.Digits != 5 || s.Period != 30 {
+		t.Errorf("unexpected entry: %+v", s)
+	}
+
+	z := zl[1]
+	if z.Label != "SPDX:James" || z.Secret != "5OM4WOOGPLQEF6UGN3CPEOOLWU======" || z.Digits != 7 || z.Algorithm != "SHA256" {
+		t.Errorf("unexpected entry: %+v", z)
+	}
+
+	if fmt.Sprint(zl[0].Tags) != "[Personal]" {
+		t.Errorf("unexpected tags: %v", zl[0].Tags)
+	}
+
+	// codes can be generated from imported (unpadded) secrets
+	for _, z := range zl {
+		_, err = otp.GenerateOTP(&z)
+		if err != nil {
+			t.Fatalf("%s: %v", z.Label, err)
+		}
+	}
+
+	if zl[0].Notes != "Personal account" || zl[0].Misc["favorite"] != true {
+		t.Errorf("unexpected misc: %v", zl[0].Misc)
+	}
+
+	// check for encrypted file
+	_, err = a.Import(test.TestAegisEncryptedJson, "", false)
+	if err == nil {
+		t.Error("expected error importing encrypted file without password")
+	}
+
+	_, err = a.Import(test.TestAegisEncryptedJson, "wrongpass", false)
+	if err == nil {
+		t.Error("expected error importing encrypted file with wrong password")
+	}
+
+	zl, err = a.Import(test.TestAegisEncryptedJson, test.AegisEncryptedPassword, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(zl) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(zl))
+	}
+
+	// missing period takes default value
+	f := filepath.Join(t.TempDir(), "aegis.json")
+	err = os.WriteFile(f, []byte(`{"version":1,"header":{"slots":null,"params":null},"db":{"version":3,"entries":[
+		{"type":"totp","uuid":"1","name":"alice","issuer":"Example","info":{"secret":"JBSWY3DPEHPK3PXP","algo":"SHA1","digits":6}}
+	]}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zl, err = a.Import(f, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zl) != 1 || zl[0].Period != zauth.DefaultPeriod {
+		t.Fatalf("unexpected entries: %+v", zl)
+	}
+
+	_, err = otp.GenerateOTP(&zl[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportHotp(t *testing.T) {
+	a := NewAegis(common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}))
+	test.RemoveTestFiles()
+	defer test.RemoveTestFiles()
+
+	// RFC 4226 test secret, lower-cased as written by some exporters
+	f := filepath.Join(t.TempDir(), "aegis.json")
+	err := os.WriteFile(f, []byte(`{"version":1,"header":{"slots":null,"params":null},"db":{"version":3,"entries":[
+		{"type":"hotp","uuid":"1","name":"alice","issuer":"Example","info":{"secret":"gezdgnbvgy3tqojqgezdgnbvgy3tqojq","algo":"SHA1","digits":6,"counter":1}},
+		{"type":"totp","uuid":"2","name":"bob","issuer":"Example","info":{"secret":"mfrgg","algo":"SHA1","digits":6,"period":30}},
+		{"type":"totp","uuid":"3","name":"eve","issuer":"Example","info":{"secret":"not base32!","algo":"SHA1","digits":6,"period":30}}
+	]}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zl, err := a.Import(f, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entry with invalid secret is skipped
+	if len(zl) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(zl))
+	}
+
+	o, err := otp.GenerateOTP(&zl[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Otp != "287082" {
+		t.Errorf("expected code 287082, got %s", o.Otp)
+	}
+
+	if zl[1].Secret != "MFRGG===" {
+		t.Errorf("unexpected secret: %s", zl[1].Secret)
+	}
+	_, err = otp.GenerateOTP(&zl[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportScryptParams(t *testing.T) {
+	b, err := os.ReadFile(test.TestAegisEncryptedJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := aegisVault{}
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// parameters of untrusted files are bounded before deriving key
+	for _, p := range [][3]int{{1 << 30, 8, 1}, {32768, 1 << 20, 1}, {32768, 8, 1 << 20}, {30000, 8, 1}, {0, 8, 1}} {
+		for i := range v.Header.Slots {
+			v.Header.Slots[i].N, v.Header.Slots[i].R, v.Header.Slots[i].P = p[0], p[1], p[2]
+		}
+
+		_, err = decodeDb(&v, test.AegisEncryptedPassword)
+		if err == nil || !strings.Contains(err.Error(), "scrypt") {
+			t.Errorf("expected error for scrypt parameters %v, got %v", p, err)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	a := NewAegis(common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}))
+	test.RemoveTestFiles()
+	defer test.RemoveTestFiles()
+
+	zl, err := a.Import(test.TestAegisPlainJson, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pwd := range []string{"", "testpass"} {
+		f, err := a.Export(pwd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// exported file must import back without loss
+		il, err := a.Import(*f, pwd, true)
+		os.Remove(*f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// imported entries are assigned new IDs
+		for i := range il {
+			il[i].ID = zl[i].ID
+		}
+
+		if fmt.Sprint(il) != fmt.Sprint(zl) {
+			t.Errorf("round trip mismatch:\n%v\n%v", zl, il)
+		}
+	}
+}
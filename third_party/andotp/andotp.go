@@ -10,23 +10,26 @@ import (
 )
 
 type andotpNode struct {
-	Secret         string        `json:"secret"`
-	Issuer         string        `json:"issuer"`
-	Label          string        `json:"label"`
-	Digits         int           `json:"digits"`
-	Type           string        `json:"type"`
-	Algorithm      string        `json:"alogrithm"`
-	Thumbnail      string        `json:"thumbnail"`
-	Last_used      int64         `json:"last_used"`
-	Used_frequency int           `json:"used_frequency"`
-	Period         int           `json:"period"`
-	Tags           []interface{} `json:"tags"`
+	Secret         string   `json:"secret"`
+	Issuer         string   `json:"issuer"`
+	Label          string   `json:"label"`
+	Digits         int      `json:"digits"`
+	Type           string   `json:"type"`
+	Algorithm      string   `json:"alogrithm"`
+	Thumbnail      string   `json:"thumbnail"`
+	Last_used      int64    `json:"last_used"`
+	Used_frequency int      `json:"used_frequency"`
+	Period         int      `json:"period"`
+	Tags           []string `json:"tags"`
 }
 
-type AndOtpImportExport struct{}
+type AndOtpImportExport struct {
+	store *common.Store
+}
 
-func NewAndOtp() AndOtpImportExport {
-	return AndOtpImportExport{}
+// NewAndOtp returns andOTP importer/exporter reading and writing entries to store s.
+func NewAndOtp(s *common.Store) AndOtpImportExport {
+	return AndOtpImportExport{store: s}
 }
 
 // Import imports andOTP encrypted/decrypted file f and returns ZAuth object and any errors encountered.
@@ -69,11 +72,11 @@ func (a AndOtpImportExport) Import(f string, pwd string, ow bool) ([]zauth.ZAuth
 		z.Period = int64(node.Period)
 		z.Secret = node.Secret
 		z.Type = node.Type
+		z.Tags = common.NormalizeTags(node.Tags)
 		if z.Misc == nil {
 			z.Misc = make(map[string]interface{})
 		}
 		z.Misc["thumbnail"] = node.Thumbnail
-		z.Misc["tags"] = node.Tags
 		z.Misc["last_used"] = node.Last_used
 		z.Misc["used_frequency"] = node.Used_frequency
 
@@ -81,7 +84,7 @@ func (a AndOtpImportExport) Import(f string, pwd string, ow bool) ([]zauth.ZAuth
 		z = zauth.ZAuth{}
 	}
 
-	err = common.WriteZAuthJson(zl, ow)
+	err = a.store.WriteZAuthJson(zl, ow)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +100,7 @@ func (a AndOtpImportExport) Export(pwd string) (*string, error) {
 	al := make([]andotpNode, 0)
 	an := andotpNode{}
 
-	zj, err := common.ReadZAuthJson()
+	zj, err := a.store.ReadZAuthJson()
 	if err != nil {
 		return nil, err
 	}
@@ -110,6 +113,7 @@ func (a AndOtpImportExport) Export(pwd string) (*string, error) {
 		an.Period = int(z.Period)
 		an.Secret = z.Secret
 		an.Type = z.Type
+		an.Tags = common.NormalizeTags(z.Tags)
 		if z.Misc == nil {
 			an.Thumbnail = "Default"
 		} else {
@@ -118,9 +122,6 @@ func (a AndOtpImportExport) Export(pwd string) (*string, error) {
 			} else {
 				an.Thumbnail = "Default"
 			}
-			if z.Misc["tags"] != nil {
-				an.Tags = z.Misc["tags"].([]interface{})
-			}
 			if z.Misc["last_used"] != nil {
 				an.Last_used = int64(z.Misc["last_used"].(float64))
 			}
@@ -146,7 +147,7 @@ func (a AndOtpImportExport) Export(pwd string) (*string, error) {
 		}
 	}
 
-	fl, err := common.WriteFile(d, common.GetFileName("andotp", enc))
+	fl, err := common.WriteFile(d, a.store.Dir, common.GetFileName("andotp", enc))
 	if err != nil {
 		return nil, err
 	}
@@ -4,17 +4,12 @@ import (
 	"os"
 	"testing"
 
-	"github.com/grijul/zauth/internal/zauth"
+	"github.com/grijul/zauth/internal/common"
 	"github.com/grijul/zauth/test"
 )
 
-func init() {
-	zauth.ZAuthJson = test.TestZAuthJson
-	zauth.ZAuthJsonDir = test.TestZAuthJsonDir
-}
-
 func TestImport(t *testing.T) {
-	o := NewAndOtp()
+	o := NewAndOtp(common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}))
 	test.RemoveTestFiles()
 
 	// check for unencrypted file
@@ -34,7 +29,7 @@ func TestImport(t *testing.T) {
 }
 
 func TestExport(t *testing.T) {
-	o := NewAndOtp()
+	o := NewAndOtp(common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}))
 
 	// check for unencrypted file
 	f, err := o.Export("")
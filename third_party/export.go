@@ -3,10 +3,12 @@ package third_party
 import (
 	"fmt"
 
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/third_party/aegis"
 	"github.com/grijul/zauth/third_party/andotp"
 )
 
-var SupportedExportTypes = []string{"andotp"}
+var SupportedExportTypes = []string{"aegis", "andotp"}
 
 type ExportFile interface {
 	// Export exports zauth entries to file. Exported file may be encrypted with password p. If p is empty, unencrypted file is exported.
@@ -14,12 +16,18 @@ type ExportFile interface {
 	Export(p string) (*string, error)
 }
 
-// NewExportFile returns ExportFile interface implemented by import type t
-func NewExportFile(t *string) (ExportFile, error) {
+// NewExportFile returns ExportFile interface implemented by import type t.
+// Exported entries are read from store s.
+func NewExportFile(t *string, s *common.Store) (ExportFile, error) {
 	switch *t {
+	case "aegis":
+		{
+			return aegis.NewAegis(s), nil
+		}
+
 	case "andotp":
 		{
-			return andotp.NewAndOtp(), nil
+			return andotp.NewAndOtp(s), nil
 		}
 
 	default:
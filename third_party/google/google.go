@@ -0,0 +1,285 @@
+package google
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/qr"
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+// Google Authenticator "Transfer accounts" export.
+// Each QR code contains otpauth-migration://offline?data=... URI where data is base64 encoded MigrationPayload protobuf message:
+//
+//	message MigrationPayload {
+//	  enum Algorithm { ALGORITHM_UNSPECIFIED = 0; SHA1 = 1; SHA256 = 2; SHA512 = 3; MD5 = 4; }
+//	  enum DigitCount { DIGIT_COUNT_UNSPECIFIED = 0; SIX = 1; EIGHT = 2; }
+//	  enum OtpType { OTP_TYPE_UNSPECIFIED = 0; HOTP = 1; TOTP = 2; }
+//	  message OtpParameters {
+//	    bytes secret = 1;
+//	    string name = 2;
+//	    string issuer = 3;
+//	    Algorithm algorithm = 4;
+//	    DigitCount digits = 5;
+//	    OtpType type = 6;
+//	    int64 counter = 7;
+//	  }
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2;
+//	  int32 batch_size = 3;
+//	  int32 batch_index = 4;
+//	  int32 batch_id = 5;
+//	}
+//
+// Large exports are split in several QR codes (batches) sharing the same batch_id.
+const migrationScheme = "otpauth-migration"
+
+type otpParameters struct {
+	secret    []byte
+	name      string
+	issuer    string
+	algorithm uint64
+	digits    uint64
+	typ       uint64
+	counter   int64
+}
+
+type migrationPayload struct {
+	otpParameters []otpParameters
+	version       int64
+	batchSize     int64
+	batchIndex    int64
+	batchId       int64
+}
+
+type GoogleImport struct {
+	store *common.Store
+}
+
+// NewGoogle returns Google Authenticator export importer writing entries to store s.
+func NewGoogle(s *common.Store) GoogleImport {
+	return GoogleImport{store: s}
+}
+
+// Import imports Google Authenticator export f and returns ZAuth object and any errors encountered.
+// f is a QR code image (screenshot of "Transfer accounts" QR code) or a text file containing otpauth-migration:// URIs (one per line).
+// f may be a glob pattern (eg: "export-*.png") to import an export split in several QR codes. Password pwd is not used.
+// If ow is true, existing zauth.json file is overwritten. Else entries are appended.
+func (g GoogleImport) Import(f string, pwd string, ow bool) ([]zauth.ZAuth, error) {
+	files, err := filepath.Glob(f)
+	if err != nil || len(files) == 0 {
+		files = []string{f}
+	}
+
+	uris := make([]string, 0)
+	for _, fl := range files {
+		u, err := readUris(fl)
+		if err != nil {
+			return nil, err
+		}
+		uris = append(uris, u...)
+	}
+
+	pl := make([]*migrationPayload, 0, len(uris))
+	for _, u := range uris {
+		p, err := parseUri(u)
+		if err != nil {
+			return nil, err
+		}
+		pl = append(pl, p)
+	}
+
+	if len(pl) == 0 {
+		return nil, fmt.Errorf("no %s:// URI found in %s", migrationScheme, f)
+	}
+
+	pl, err = assembleBatches(pl)
+	if err != nil {
+		return nil, err
+	}
+
+	zl := make([]zauth.ZAuth, 0)
+	for _, p := range pl {
+		for _, o := range p.otpParameters {
+			z, err := toZAuth(&o)
+			if err != nil {
+				return nil, err
+			}
+			zl = append(zl, *z)
+		}
+	}
+
+	err = g.store.WriteZAuthJson(zl, ow)
+	if err != nil {
+		return nil, err
+	}
+
+	return zl, nil
+}
+
+// readUris returns otpauth-migration URIs from QR code image or text file f.
+func readUris(f string) ([]string, error) {
+	txt, err := qr.DecodeFile(f)
+	if err != nil {
+		if !errors.Is(err, image.ErrFormat) {
+			return nil, err
+		}
+
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		txt = strings.Split(string(b), "\n")
+	}
+
+	uris := make([]string, 0)
+	for _, t := range txt {
+		t = strings.TrimSpace(t)
+		if strings.HasPrefix(t, migrationScheme+"://") {
+			uris = append(uris, t)
+		}
+	}
+
+	return uris, nil
+}
+
+// parseUri parses otpauth-migration URI u and returns it's decoded payload.
+func parseUri(u string) (*migrationPayload, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if pu.Scheme != migrationScheme || pu.Host != "offline" {
+		return nil, fmt.Errorf("invalid %s uri", migrationScheme)
+	}
+
+	d := pu.Query().Get("data")
+	if d == "" {
+		return nil, fmt.Errorf("invalid %s uri: missing data", migrationScheme)
+	}
+
+	// '+' may be left unescaped by some QR scanners and is read as space
+	d = strings.ReplaceAll(d, " ", "+")
+	b, err := base64.StdEncoding.DecodeString(d)
+	if err != nil {
+		b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(d, "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s uri: %v", migrationScheme, err)
+		}
+	}
+
+	return decodePayload(b)
+}
+
+// assembleBatches groups payloads by batch id and verifies that all batches of every export are present.
+// Returns payloads ordered by batch id and index. Duplicate batches (eg: same QR code scanned twice) are ignored.
+func assembleBatches(pl []*migrationPayload) ([]*migrationPayload, error) {
+	exports := make(map[int64]map[int64]*migrationPayload)
+	ids := make([]int64, 0)
+	for _, p := range pl {
+		if exports[p.batchId] == nil {
+			exports[p.batchId] = make(map[int64]*migrationPayload)
+			ids = append(ids, p.batchId)
+		}
+		exports[p.batchId][p.batchIndex] = p
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	res := make([]*migrationPayload, 0, len(pl))
+	for _, id := range ids {
+		batches := exports[id]
+
+		size := int64(1)
+		for _, p := range batches {
+			if p.batchSize > size {
+				size = p.batchSize
+			}
+		}
+
+		for i := int64(0); i < size; i++ {
+			p, ok := batches[i]
+			if !ok {
+				return nil, fmt.Errorf("incomplete export: batch %d of %d is missing", i+1, size)
+			}
+			res = append(res, p)
+		}
+	}
+
+	return res, nil
+}
+
+// toZAuth converts OTP parameters o to ZAuth object.
+func toZAuth(o *otpParameters) (*zauth.ZAuth, error) {
+	z := &zauth.ZAuth{
+		Secret: base32.StdEncoding.EncodeToString(o.secret),
+		Issuer: strings.TrimSpace(o.issuer),
+		Period: zauth.DefaultPeriod,
+	}
+
+	if len(o.secret) == 0 {
+		return nil, fmt.Errorf("entry %s has no secret", o.name)
+	}
+
+	account := strings.TrimSpace(o.name)
+	if i := strings.Index(account, ":"); i >= 0 {
+		if z.Issuer == "" {
+			z.Issuer = strings.TrimSpace(account[:i])
+		}
+		account = strings.TrimSpace(account[i+1:])
+	}
+
+	if z.Issuer != "" {
+		z.Label = fmt.Sprintf("%s:%s", z.Issuer, account)
+	} else {
+		z.Label = account
+	}
+
+	switch o.algorithm {
+	case 0, 1:
+		z.Algorithm = "SHA1"
+	case 2:
+		z.Algorithm = "SHA256"
+	case 3:
+		z.Algorithm = "SHA512"
+	default:
+		return nil, fmt.Errorf("entry %s: unsupported algorithm (%d)", z.Label, o.algorithm)
+	}
+
+	switch o.digits {
+	case 0, 1:
+		z.Digits = 6
+	case 2:
+		z.Digits = 8
+	default:
+		return nil, fmt.Errorf("entry %s: unsupported digits (%d)", z.Label, o.digits)
+	}
+
+	switch o.typ {
+	case 0, 2:
+		z.Type = "totp"
+	case 1:
+		z.Type = "hotp"
+		z.Counter = o.counter
+		z.Period = 0
+	default:
+		return nil, fmt.Errorf("entry %s: unsupported type (%d)", z.Label, o.typ)
+	}
+
+	// HOTP codes are only generated with SHA1
+	if z.Type == "hotp" && z.Algorithm != "SHA1" {
+		return nil, fmt.Errorf("entry %s: unsupported HOTP algorithm (%s)", z.Label, z.Algorithm)
+	}
+
+	return z, nil
+}
@@ -0,0 +1,172 @@
+package google
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/test"
+)
+
+// exampleUri is a single TOTP entry (Example:alice@google.com, secret JBSWY3DPEHPK3PXP) exported by Google Authenticator.
+const exampleUri = "otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZTAC"
+
+func appendField(b []byte, f int, wt int) []byte {
+	return appendVarint(b, uint64(f<<3|wt))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func appendBytes(b []byte, f int, v []byte) []byte {
+	b = appendField(b, f, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// migrationUri encodes payload p as otpauth-migration URI.
+func migrationUri(p *migrationPayload) string {
+	var b []byte
+	for _, o := range p.otpParameters {
+		var m []byte
+		m = appendBytes(m, 1, o.secret)
+		m = appendBytes(m, 2, []byte(o.name))
+		m = appendBytes(m, 3, []byte(o.issuer))
+		for f, v := range map[int]uint64{4: o.algorithm, 5: o.digits, 6: o.typ, 7: uint64(o.counter)} {
+			m = appendField(m, f, wireVarint)
+			m = appendVarint(m, v)
+		}
+		b = appendBytes(b, 1, m)
+	}
+
+	for f, v := range map[int]int64{2: p.version, 3: p.batchSize, 4: p.batchIndex, 5: p.batchId} {
+		b = appendField(b, f, wireVarint)
+		b = appendVarint(b, uint64(v))
+	}
+
+	return fmt.Sprintf("otpauth-migration://offline?data=%s", url.QueryEscape(base64.StdEncoding.EncodeToString(b)))
+}
+
+func writeFile(t *testing.T, dir string, name string, lines ...string) string {
+	f := filepath.Join(dir, name)
+	err := os.WriteFile(f, []byte(strings.Join(lines, "\n")), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestParseUri(t *testing.T) {
+	p, err := parseUri(exampleUri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.otpParameters) != 1 {
+		t.Fatal("expected entries count: 1. received: ", len(p.otpParameters))
+	}
+
+	z, err := toZAuth(&p.otpParameters[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if z.Secret != "JBSWY3DPEHPK3PXP" || z.Issuer != "Example" || z.Label != "Example:alice@google.com" ||
+		z.Type != "totp" || z.Digits != 6 || z.Algorithm != "SHA1" || z.Period != 30 {
+		t.Fatal("unexpected entry: ", z)
+	}
+
+	for _, u := range []string{
+		"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth-migration://offline",
+		"otpauth-migration://offline?data=CjEKCkhlbGxv",
+	} {
+		_, err = parseUri(u)
+		if err == nil {
+			t.Fatal("expected test to fail for uri: ", u)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	dir := t.TempDir()
+	g := NewGoogle(common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}))
+
+	hotp := otpParameters{secret: []byte("12345678901234567890"), name: "jane", issuer: "ACME", algorithm: 1, digits: 2, typ: 1, counter: 7}
+	sha256 := otpParameters{secret: []byte("12345678901234567890"), name: "ACME:john", algorithm: 2, digits: 1, typ: 2}
+	md5 := otpParameters{secret: []byte("12345678901234567890"), name: "md5", algorithm: 4, typ: 2}
+	hotpSha256 := otpParameters{secret: []byte("12345678901234567890"), name: "hotp", algorithm: 2, typ: 1, counter: 1}
+
+	batch1 := migrationUri(&migrationPayload{otpParameters: []otpParameters{hotp}, version: 1, batchSize: 2, batchIndex: 0, batchId: 42})
+	batch2 := migrationUri(&migrationPayload{otpParameters: []otpParameters{sha256}, version: 1, batchSize: 2, batchIndex: 1, batchId: 42})
+
+	// single payload
+	zl, err := g.Import(writeFile(t, dir, "single.txt", exampleUri), "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zl) != 1 {
+		t.Fatal("expected entries count: 1. received: ", len(zl))
+	}
+
+	// batches in separate files, second batch scanned twice
+	writeFile(t, dir, "batch-2.txt", batch2, batch2)
+	writeFile(t, dir, "batch-1.txt", batch1)
+	zl, err = g.Import(filepath.Join(dir, "batch-*.txt"), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zl) != 2 {
+		t.Fatal("expected entries count: 2. received: ", len(zl))
+	}
+
+	if zl[0].Type != "hotp" || zl[0].Counter != 7 || zl[0].Digits != 8 || zl[0].Label != "ACME:jane" {
+		t.Fatal("unexpected entry: ", zl[0])
+	}
+	if zl[1].Type != "totp" || zl[1].Algorithm != "SHA256" || zl[1].Issuer != "ACME" || zl[1].Label != "ACME:john" {
+		t.Fatal("unexpected entry: ", zl[1])
+	}
+
+	z, err := common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}).ReadZAuthJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z) != 3 {
+		t.Fatal("expected entries count: 3. received: ", len(z))
+	}
+
+	// missing batch
+	_, err = g.Import(writeFile(t, dir, "missing.txt", batch1), "", false)
+	if err == nil {
+		t.Fatal("expected test to fail when batch is missing")
+	}
+
+	// unsupported algorithm
+	_, err = g.Import(writeFile(t, dir, "md5.txt", migrationUri(&migrationPayload{otpParameters: []otpParameters{md5}, batchSize: 1})), "", false)
+	if err == nil {
+		t.Fatal("expected test to fail when algorithm is unsupported")
+	}
+
+	// HOTP codes are only generated with SHA1
+	_, err = g.Import(writeFile(t, dir, "hotp.txt", migrationUri(&migrationPayload{otpParameters: []otpParameters{hotpSha256}, batchSize: 1})), "", false)
+	if err == nil {
+		t.Fatal("expected test to fail when HOTP algorithm is unsupported")
+	}
+
+	// no uri
+	_, err = g.Import(test.TestAndotpAccountsJson, "", false)
+	if err == nil {
+		t.Fatal("expected test to fail when file contains no uri")
+	}
+}
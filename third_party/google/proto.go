@@ -0,0 +1,182 @@
+package google
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Minimal protobuf wire format decoder for MigrationPayload message.
+// See https://developers.google.com/protocol-buffers/docs/encoding
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("invalid migration payload: message truncated")
+
+type protoReader struct {
+	b []byte
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.b = r.b[n:]
+	return v, nil
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	l, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(len(r.b)) {
+		return nil, errTruncated
+	}
+	v := r.b[:l]
+	r.b = r.b[l:]
+	return v, nil
+}
+
+// next returns next field number and wire type.
+func (r *protoReader) next() (int, int, error) {
+	k, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(k >> 3), int(k & 7), nil
+}
+
+// skip skips value of wire type wt.
+func (r *protoReader) skip(wt int) error {
+	var n int
+	switch wt {
+	case wireVarint:
+		_, err := r.varint()
+		return err
+	case wireBytes:
+		_, err := r.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("invalid migration payload: unsupported wire type %d", wt)
+	}
+
+	if len(r.b) < n {
+		return errTruncated
+	}
+	r.b = r.b[n:]
+	return nil
+}
+
+// decodePayload decodes MigrationPayload message b.
+func decodePayload(b []byte) (*migrationPayload, error) {
+	p := &migrationPayload{}
+	r := &protoReader{b: b}
+
+	for len(r.b) > 0 {
+		f, wt, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case f == 1 && wt == wireBytes:
+			m, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			o, err := decodeOtpParameters(m)
+			if err != nil {
+				return nil, err
+			}
+			p.otpParameters = append(p.otpParameters, *o)
+
+		case f >= 2 && f <= 5 && wt == wireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			switch f {
+			case 2:
+				p.version = int64(v)
+			case 3:
+				p.batchSize = int64(v)
+			case 4:
+				p.batchIndex = int64(v)
+			case 5:
+				p.batchId = int64(int32(v))
+			}
+
+		default:
+			err = r.skip(wt)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// decodeOtpParameters decodes OtpParameters message b.
+func decodeOtpParameters(b []byte) (*otpParameters, error) {
+	o := &otpParameters{}
+	r := &protoReader{b: b}
+
+	for len(r.b) > 0 {
+		f, wt, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case f >= 1 && f <= 3 && wt == wireBytes:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			switch f {
+			case 1:
+				o.secret = append([]byte(nil), v...)
+			case 2:
+				o.name = string(v)
+			case 3:
+				o.issuer = string(v)
+			}
+
+		case f >= 4 && f <= 7 && wt == wireVarint:
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			switch f {
+			case 4:
+				o.algorithm = v
+			case 5:
+				o.digits = v
+			case 6:
+				o.typ = v
+			case 7:
+				o.counter = int64(v)
+			}
+
+		default:
+			err = r.skip(wt)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return o, nil
+}
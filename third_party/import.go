@@ -3,11 +3,15 @@ package third_party
 import (
 	"fmt"
 
+	"github.com/grijul/zauth/internal/common"
 	"github.com/grijul/zauth/internal/zauth"
+	"github.com/grijul/zauth/third_party/aegis"
 	"github.com/grijul/zauth/third_party/andotp"
+	"github.com/grijul/zauth/third_party/google"
+	"github.com/grijul/zauth/third_party/qrimage"
 )
 
-var SupportedImportTypes = []string{"andotp"}
+var SupportedImportTypes = []string{"aegis", "andotp", "google", "qr"}
 
 type ImportFile interface {
 	// Import imports encrypted/decrypted file f and returns ZAuth object and any errors encountered.
@@ -16,12 +20,28 @@ type ImportFile interface {
 	Import(f string, p string, ow bool) ([]zauth.ZAuth, error)
 }
 
-// NewImportFile returns ImportFile interface implemented by import type t
-func NewImportFile(t *string) (ImportFile, error) {
+// NewImportFile returns ImportFile interface implemented by import type t.
+// Imported entries are written to store s.
+func NewImportFile(t *string, s *common.Store) (ImportFile, error) {
 	switch *t {
+	case "aegis":
+		{
+			return aegis.NewAegis(s), nil
+		}
+
 	case "andotp":
 		{
-			return andotp.NewAndOtp(), nil
+			return andotp.NewAndOtp(s), nil
+		}
+
+	case "google":
+		{
+			return google.NewGoogle(s), nil
+		}
+
+	case "qr":
+		{
+			return qrimage.NewQrImage(s), nil
 		}
 
 	default:
@@ -0,0 +1,54 @@
+package qrimage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/internal/oauthurl"
+	"github.com/grijul/zauth/internal/qr"
+	"github.com/grijul/zauth/internal/zauth"
+)
+
+type QrImageImport struct {
+	store *common.Store
+}
+
+// NewQrImage returns QR code image importer writing entries to store s.
+func NewQrImage(s *common.Store) QrImageImport {
+	return QrImageImport{store: s}
+}
+
+// Import decodes otpauth:// QR codes in PNG/JPEG/GIF image f and returns ZAuth object and any errors encountered.
+// Image may contain several QR codes. Password pwd is not used.
+// If ow is true, existing zauth.json file is overwritten. Else entries are appended.
+func (q QrImageImport) Import(f string, pwd string, ow bool) ([]zauth.ZAuth, error) {
+	txt, err := qr.DecodeFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	zl := make([]zauth.ZAuth, 0)
+	for _, t := range txt {
+		if !strings.HasPrefix(t, "otpauth://") {
+			continue
+		}
+
+		z, err := oauthurl.Parse(t)
+		if err != nil {
+			return nil, err
+		}
+		zl = append(zl, *z)
+	}
+
+	if len(zl) == 0 {
+		return nil, fmt.Errorf("no otpauth:// QR code found in %s", f)
+	}
+
+	err = q.store.WriteZAuthJson(zl, ow)
+	if err != nil {
+		return nil, err
+	}
+
+	return zl, nil
+}
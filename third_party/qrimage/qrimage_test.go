@@ -0,0 +1,30 @@
+package qrimage
+
+import (
+	"testing"
+
+	"github.com/grijul/zauth/internal/common"
+	"github.com/grijul/zauth/test"
+)
+
+func TestImport(t *testing.T) {
+	defer test.RemoveTestFiles()
+	test.RemoveTestFiles()
+
+	o := NewQrImage(common.NewStore(test.TestZAuthJsonDir, &test.VaultPasswordReader{}))
+
+	zl, err := o.Import(test.TestQrAccountsPng, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(zl) != 2 {
+		t.Fatal("expected entries count: 2. received: ", len(zl))
+	}
+
+	// not an image
+	_, err = o.Import(test.TestAndotpAccountsJson, "", false)
+	if err == nil {
+		t.Fatal("expected test to fail when file is not an image")
+	}
+}